@@ -3,9 +3,50 @@ package config
 var Protocol = "tcp"
 var Port = ":3000"
 var MaxConnection = 20000
-var MaxKeyNumber int = 10
-var EvictionRatio = 0.1
+var IOBufLen = 16 * 1024
+
+// largest bulk string or array length a client may send, like Redis proto-max-bulk-len
+var ProtoMaxBulkLen int64 = 512 * 1024 * 1024
+
+// a client whose unparsed input grows past this is disconnected, like Redis client-query-buffer-limit
+var ClientQueryBufferLimit = 1024 * 1024 * 1024
+
+// like Redis client-output-buffer-limit, in bytes, 0 disables the limit
+var ClientOutputBufferLimitHard = 256 * 1024 * 1024
+var ClientOutputBufferLimitSoft = 64 * 1024 * 1024
+var ClientOutputBufferLimitSoftSeconds = 60
+
+// 1 to 10, like Redis active-expire-effort, higher values spend more CPU to reclaim expired keys sooner
+var ActiveExpireEffort = 1
+
+// number of logical databases selectable with SELECT, like Redis databases
+var Databases = 16
+
+// like Redis maxmemory, in bytes, keys are evicted while the keyspace uses more, 0 disables the limit
+var MaxMemory int64 = 0
 var EvictionPolicy string = "allkeys-random"
 var EpoolMaxSize = 16
-var EpoolLruSampleSize = 5
-var EpoolLfuSampleSize = 5
+
+// keys sampled per database to find an eviction candidate, like Redis maxmemory-samples
+var MaxMemorySamples = 5
+
+// like Redis lfu-log-factor, the higher the more accesses it takes to grow the LFU counter
+var LfuLogFactor = 10
+
+// like Redis lfu-decay-time, minutes after which the LFU counter is decremented, 0 never decays it
+var LfuDecayTime = 1
+
+// like Redis set-max-intset-entries, sets of integers up to this size are stored as a sorted array
+var SetMaxIntsetEntries = 512
+
+// like Redis zset-max-listpack-entries, sorted sets up to this size are stored as a sorted array
+var ZsetMaxListpackEntries = 128
+
+// like Redis list-max-listpack-size, a positive value is the number of elements per list node,
+// -1 to -5 limit a node to 4, 8, 16, 32 or 64 KB
+var ListMaxListpackSize = -2
+
+// like Redis lazyfree-lazy-*, large values deleted by eviction, by expiry or by DEL are released in the background
+var LazyfreeLazyEviction = false
+var LazyfreeLazyExpire = false
+var LazyfreeLazyUserDel = false
@@ -9,12 +9,12 @@ var RespOne = []byte(":1\r\n")
 var TtlKeyNotExist = []byte(":-2\r\n")
 var TtlKeyExistNoExpire = []byte(":-1\r\n")
 var ActiveExpireFrequency = 100 * time.Millisecond
-var ActiveExpireSampleSize = 20
-var ActiveExpireThreshold = 0.1
+var ActiveExpireKeysPerLoop = 20
 var ExpireKeySuccess = []byte(":1\r\n")
 var ExpireKeyNotExist = []byte(":0\r\n")
 var DefaultBPlusTreeDegree = 4
 
+const ServerVersion = "7.0.0"
 const BfDefaultInitCapacity = 100
 const BfDefaultErrRate = 0.01
 const ServerStatusIdle = 1
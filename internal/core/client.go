@@ -0,0 +1,119 @@
+package core
+
+import (
+	"redis-clone/internal/config"
+	"redis-clone/internal/data_structure"
+	"syscall"
+	"time"
+)
+
+// nextClientID is the ID given to the next accepted client, IDs are never reused.
+var nextClientID int64 = 1
+
+// Client holds the state the event loop keeps for each accepted connection.
+type Client struct {
+	Fd   int
+	ID   int64
+	Name string
+	// DB is the index of the database selected with SELECT.
+	DB int
+	// Proto is the RESP version replies are encoded with, switched with HELLO.
+	Proto int
+	// QueryBuf accumulates bytes read from the socket until they form complete commands.
+	QueryBuf []byte
+	// outBuf holds replies that have not been accepted by the kernel yet,
+	// outBuf[:sentLen] has already been written.
+	outBuf  []byte
+	sentLen int
+	// softLimitSince is when the pending output first went over the soft limit.
+	softLimitSince time.Time
+	// WaitingWritable is true while the event loop watches Fd for writability instead of reads.
+	WaitingWritable bool
+	// CloseAfterReply is set after a protocol error, the client is closed once its pending replies are sent.
+	CloseAfterReply bool
+	// CloseAsap is set when the client must be disconnected, e.g. it reads its replies too slowly.
+	CloseAsap bool
+}
+
+func NewClient(fd int) *Client {
+	c := &Client{
+		Fd:    fd,
+		ID:    nextClientID,
+		Proto: Resp2,
+	}
+	nextClientID++
+	return c
+}
+
+// db returns the database selected by the client.
+func (c *Client) db() *data_structure.Dict {
+	return dbs[c.DB]
+}
+
+// Encode encodes value with the protocol version negotiated by the client.
+func (c *Client) Encode(value interface{}) []byte {
+	return EncodeProto(value, c.Proto)
+}
+
+// Write queues a reply for the client. It is sent by Flush.
+func (c *Client) Write(b []byte) {
+	if c.CloseAsap {
+		return
+	}
+	c.outBuf = append(c.outBuf, b...)
+	c.checkOutputBufferLimits()
+}
+
+func (c *Client) PendingReplyBytes() int {
+	return len(c.outBuf) - c.sentLen
+}
+
+func (c *Client) HasPendingReplies() bool {
+	return c.PendingReplyBytes() > 0
+}
+
+// Flush writes as much of the pending output as the kernel accepts without blocking.
+// A full socket buffer is not an error: the rest stays queued for the next writable event.
+func (c *Client) Flush() error {
+	for c.sentLen < len(c.outBuf) {
+		n, err := syscall.Write(c.Fd, c.outBuf[c.sentLen:])
+		if err == syscall.EINTR {
+			continue
+		}
+		if err == syscall.EAGAIN {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		c.sentLen += n
+	}
+	if c.sentLen == len(c.outBuf) {
+		c.outBuf = c.outBuf[:0]
+		c.sentLen = 0
+	}
+	c.checkOutputBufferLimits()
+	return nil
+}
+
+// checkOutputBufferLimits marks the client for closing when the pending output goes over
+// the hard limit, or stays over the soft limit longer than ClientOutputBufferLimitSoftSeconds.
+// A limit of 0 disables the check.
+func (c *Client) checkOutputBufferLimits() {
+	pending := c.PendingReplyBytes()
+	if config.ClientOutputBufferLimitHard > 0 && pending > config.ClientOutputBufferLimitHard {
+		c.CloseAsap = true
+		return
+	}
+	if config.ClientOutputBufferLimitSoft == 0 || pending <= config.ClientOutputBufferLimitSoft {
+		c.softLimitSince = time.Time{}
+		return
+	}
+	if c.softLimitSince.IsZero() {
+		c.softLimitSince = time.Now()
+		return
+	}
+	if time.Since(c.softLimitSince) > time.Duration(config.ClientOutputBufferLimitSoftSeconds)*time.Second {
+		c.CloseAsap = true
+	}
+}
@@ -0,0 +1,576 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"redis-clone/internal/config"
+	"redis-clone/internal/constant"
+	"redis-clone/internal/data_structure"
+	"strconv"
+	"strings"
+)
+
+var errBitOffset = errors.New("ERR bit offset is not an integer or out of range")
+
+// parseBitOffset parses a bit offset, with hash set BITFIELD also accepts #N for the
+// N-th field of width fieldBits.
+func parseBitOffset(arg string, hash bool, fieldBits int64) (int64, error) {
+	useHash := hash && strings.HasPrefix(arg, "#")
+	if useHash {
+		arg = arg[1:]
+	}
+	offset, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return 0, errBitOffset
+	}
+	if useHash {
+		if offset > math.MaxInt64/fieldBits || offset < math.MinInt64/fieldBits {
+			return 0, errBitOffset
+		}
+		offset *= fieldBits
+	}
+	if offset < 0 || offset>>3 >= config.ProtoMaxBulkLen {
+		return 0, errBitOffset
+	}
+	return offset, nil
+}
+
+// lookupBitmap returns the bytes of the string at key, empty when it is missing. They
+// are shared with the stored value and must not be modified.
+func lookupBitmap(db *data_structure.Dict, key string) ([]byte, error) {
+	obj, err := lookupKey(db, key, data_structure.ObjTypeString)
+	if err != nil || obj == nil {
+		return nil, err
+	}
+	return data_structure.StringBytes(obj.Value), nil
+}
+
+// bitmapForWrite returns the string at key as a buffer to change in place, grown with
+// zero bytes to hold at least size bytes, and the object to store it back into, nil when
+// the key is missing.
+func bitmapForWrite(db *data_structure.Dict, key string, size int64) ([]byte, *data_structure.Obj, error) {
+	obj, err := lookupKey(db, key, data_structure.ObjTypeString)
+	if err != nil {
+		return nil, nil, err
+	}
+	var value interface{}
+	if obj != nil {
+		value = obj.Value
+	}
+	return data_structure.MutableString(value, int(size)), obj, nil
+}
+
+// storeBitmap stores buf as the value of key, an existing object keeps its TTL. The bytes
+// were changed in place, only a buffer that grew is a new slice.
+func storeBitmap(db *data_structure.Dict, key string, obj *data_structure.Obj, buf []byte) {
+	if obj != nil {
+		obj.Value = buf
+		return
+	}
+	db.Set(key, db.NewObject(buf))
+}
+
+// SETBIT key offset value
+func cmdSETBIT(c *Client, args []string) []byte {
+	db := c.db()
+	key := args[0]
+	offset, err := parseBitOffset(args[1], false, 0)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if args[2] != "0" && args[2] != "1" {
+		return Encode(errors.New("ERR bit is not an integer or out of range"), false)
+	}
+	buf, obj, err := bitmapForWrite(db, key, offset>>3+1)
+	if err != nil {
+		return Encode(err, false)
+	}
+	i, shift := offset>>3, 7-offset&7
+	old := buf[i] >> shift & 1
+	if args[2] == "1" {
+		buf[i] |= 1 << shift
+	} else {
+		buf[i] &^= 1 << shift
+	}
+	storeBitmap(db, key, obj, buf)
+	return Encode(int(old), false)
+}
+
+// GETBIT key offset
+func cmdGETBIT(c *Client, args []string) []byte {
+	offset, err := parseBitOffset(args[1], false, 0)
+	if err != nil {
+		return Encode(err, false)
+	}
+	buf, err := lookupBitmap(c.db(), args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if offset>>3 >= int64(len(buf)) {
+		return constant.RespZero
+	}
+	return Encode(int(buf[offset>>3]>>(7-offset&7)&1), false)
+}
+
+// parseBitRange parses the "start end [BYTE | BIT]" arguments of BITCOUNT and BITPOS and
+// reports whether the offsets are in bits.
+func parseBitRange(args []string) (int64, int64, bool, error) {
+	start, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return 0, 0, false, errNotInteger
+	}
+	end, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return 0, 0, false, errNotInteger
+	}
+	if len(args) == 2 || strings.EqualFold(args[2], "BYTE") {
+		return start, end, false, nil
+	}
+	if strings.EqualFold(args[2], "BIT") {
+		return start, end, true, nil
+	}
+	return 0, 0, false, errSyntax
+}
+
+// bitRangeParts splits the bytes holding the bits start to end of buf into the first
+// byte, the bytes in between and the last byte. The bits of the first and last byte
+// outside of the range are set to fill on copies, buf is shared with the stored value
+// and left untouched. When the range is within one byte there is no last byte.
+func bitRangeParts(buf []byte, start, end int64, fill byte) (first byte, mid []byte, last byte, hasLast bool) {
+	res := buf[start>>3 : end>>3+1]
+	first, last = res[0], res[len(res)-1]
+	if head := start & 7; head > 0 {
+		mask := byte(0xff) << (8 - head)
+		first = first&^mask | fill&mask
+	}
+	var tailMask byte
+	if tail := 7 - end&7; tail > 0 {
+		tailMask = byte(1)<<tail - 1
+	}
+	if len(res) == 1 {
+		return first&^tailMask | fill&tailMask, nil, 0, false
+	}
+	return first, res[1 : len(res)-1], last&^tailMask | fill&tailMask, true
+}
+
+// bitRangeCount counts the bits set from bit start to bit end of buf.
+func bitRangeCount(buf []byte, start, end int64) int {
+	first, mid, last, hasLast := bitRangeParts(buf, start, end, 0)
+	count := bits.OnesCount8(first) + data_structure.Popcount(mid)
+	if hasLast {
+		count += bits.OnesCount8(last)
+	}
+	return count
+}
+
+// bitRangePos returns the position of the first bit set to bit from bit start to bit
+// end of buf, counted from the byte holding start, or -1.
+func bitRangePos(buf []byte, start, end int64, bit int) int {
+	var fill byte
+	if bit == 0 {
+		fill = 0xff
+	}
+	first, mid, last, hasLast := bitRangeParts(buf, start, end, fill)
+	if pos := data_structure.BitPos([]byte{first}, bit); pos >= 0 {
+		return pos
+	}
+	if pos := data_structure.BitPos(mid, bit); pos >= 0 {
+		return 8 + pos
+	}
+	if hasLast {
+		if pos := data_structure.BitPos([]byte{last}, bit); pos >= 0 {
+			return 8*(len(mid)+1) + pos
+		}
+	}
+	return -1
+}
+
+// BITCOUNT key [start end [BYTE | BIT]]
+func cmdBITCOUNT(c *Client, args []string) []byte {
+	if len(args) != 1 && len(args) != 3 && len(args) != 4 {
+		return Encode(errSyntax, false)
+	}
+	var start, end int64 = 0, -1
+	var isBit bool
+	if len(args) > 1 {
+		var err error
+		if start, end, isBit, err = parseBitRange(args[1:]); err != nil {
+			return Encode(err, false)
+		}
+	}
+	buf, err := lookupBitmap(c.db(), args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	n := int64(len(buf))
+	if isBit {
+		n *= 8
+	}
+	start, end, ok := clampRange(start, end, n)
+	if !ok {
+		return constant.RespZero
+	}
+	if isBit {
+		return Encode(bitRangeCount(buf, start, end), false)
+	}
+	return Encode(data_structure.Popcount(buf[start:end+1]), false)
+}
+
+// BITPOS key bit [start [end [BYTE | BIT]]]
+func cmdBITPOS(c *Client, args []string) []byte {
+	if len(args) > 5 {
+		return Encode(errSyntax, false)
+	}
+	bit, err := strconv.Atoi(args[1])
+	if err != nil || (bit != 0 && bit != 1) {
+		return Encode(errors.New("ERR The bit argument must be 1 or 0."), false)
+	}
+	obj, err := lookupKey(c.db(), args[0], data_structure.ObjTypeString)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if obj == nil {
+		// a missing key is an empty string, padded with zeros on the right
+		if bit == 1 {
+			return Encode(-1, false)
+		}
+		return constant.RespZero
+	}
+	buf := data_structure.StringBytes(obj.Value)
+
+	var start, end int64 = 0, -1
+	var isBit bool
+	endGiven := len(args) > 3
+	switch len(args) {
+	case 3:
+		if start, err = strconv.ParseInt(args[2], 10, 64); err != nil {
+			return Encode(errNotInteger, false)
+		}
+	case 4, 5:
+		if start, end, isBit, err = parseBitRange(args[2:]); err != nil {
+			return Encode(err, false)
+		}
+	}
+	n := int64(len(buf))
+	if isBit {
+		n *= 8
+	}
+	start, end, ok := clampRange(start, end, n)
+	if !ok {
+		return Encode(-1, false)
+	}
+
+	var pos int64
+	if isBit {
+		// bits around the range must not match
+		pos = int64(bitRangePos(buf, start, end, bit))
+		if pos >= 0 {
+			pos += start &^ 7
+		}
+	} else {
+		pos = int64(data_structure.BitPos(buf[start:end+1], bit))
+		if pos >= 0 {
+			pos += start * 8
+		}
+	}
+	if pos < 0 && bit == 0 && !endGiven {
+		// without an explicit end the string is padded with zeros on the right
+		if isBit {
+			return Encode(end+1, false)
+		}
+		return Encode((end+1)*8, false)
+	}
+	return Encode(pos, false)
+}
+
+// BITOP AND | OR | XOR | NOT destkey key [key ...]
+func cmdBITOP(c *Client, args []string) []byte {
+	db := c.db()
+	op, dst, keys := strings.ToUpper(args[0]), args[1], args[2:]
+	switch op {
+	case "AND", "OR", "XOR":
+	case "NOT":
+		if len(keys) != 1 {
+			return Encode(errors.New("ERR BITOP NOT must be called with a single source key."), false)
+		}
+	default:
+		return Encode(errSyntax, false)
+	}
+	srcs := make([][]byte, len(keys))
+	size := 0
+	for i, key := range keys {
+		buf, err := lookupBitmap(db, key)
+		if err != nil {
+			return Encode(err, false)
+		}
+		srcs[i] = buf
+		size = max(size, len(buf))
+	}
+	if size == 0 {
+		db.Delete(dst)
+		return constant.RespZero
+	}
+
+	// missing bytes of the shorter sources are zeros
+	res := make([]byte, size)
+	copy(res, srcs[0])
+	if op == "NOT" {
+		bitopWords(res, res, func(a, _ uint64) uint64 { return ^a })
+	}
+	for _, src := range srcs[1:] {
+		switch op {
+		case "AND":
+			bitopWords(res, src, func(a, b uint64) uint64 { return a & b })
+			clear(res[len(src):])
+		case "OR":
+			bitopWords(res, src, func(a, b uint64) uint64 { return a | b })
+		case "XOR":
+			bitopWords(res, src, func(a, b uint64) uint64 { return a ^ b })
+		}
+	}
+	db.Set(dst, db.NewObject(res))
+	return Encode(size, false)
+}
+
+// bitopWords applies fn to dst and src a 64-bit word at a time, over the length of src.
+func bitopWords(dst, src []byte, fn func(a, b uint64) uint64) {
+	i := 0
+	for ; i+8 <= len(src); i += 8 {
+		binary.LittleEndian.PutUint64(dst[i:], fn(binary.LittleEndian.Uint64(dst[i:]), binary.LittleEndian.Uint64(src[i:])))
+	}
+	for ; i < len(src); i++ {
+		dst[i] = byte(fn(uint64(dst[i]), uint64(src[i])))
+	}
+}
+
+// Overflow behaviours of BITFIELD SET and INCRBY.
+const (
+	bitfieldWrap = iota
+	bitfieldSat
+	bitfieldFail
+)
+
+type bitfieldOp struct {
+	// cmd is GET, SET or INCRBY
+	cmd      string
+	offset   int64
+	bits     int
+	signed   bool
+	value    int64
+	overflow int
+}
+
+// parseBitfieldType parses a type such as i8 or u16, u64 can not be represented in the
+// int64 replies.
+func parseBitfieldType(arg string) (bool, int, error) {
+	errType := errors.New("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+	if len(arg) < 2 || (arg[0] != 'i' && arg[0] != 'u') {
+		return false, 0, errType
+	}
+	signed := arg[0] == 'i'
+	n, err := strconv.Atoi(arg[1:])
+	if err != nil || n < 1 || (signed && n > 64) || (!signed && n > 63) {
+		return false, 0, errType
+	}
+	return signed, n, nil
+}
+
+// getBitfield reads an unsigned integer of n bits at offset, bits past the end of buf
+// read as zeros.
+func getBitfield(buf []byte, offset int64, n int) uint64 {
+	var v uint64
+	for i := 0; i < n; i++ {
+		var bit uint64
+		if offset>>3 < int64(len(buf)) {
+			bit = uint64(buf[offset>>3]>>(7-offset&7)) & 1
+		}
+		v = v<<1 | bit
+		offset++
+	}
+	return v
+}
+
+// getSignedBitfield reads a two's complement integer of n bits at offset.
+func getSignedBitfield(buf []byte, offset int64, n int) int64 {
+	v := getBitfield(buf, offset, n)
+	if n < 64 && v&(1<<(n-1)) != 0 {
+		v |= math.MaxUint64 << n
+	}
+	return int64(v)
+}
+
+// setBitfield writes the n low bits of v at offset, buf must be large enough.
+func setBitfield(buf []byte, offset int64, n int, v uint64) {
+	for i := n - 1; i >= 0; i-- {
+		bit := byte(v>>i) & 1
+		shift := 7 - offset&7
+		buf[offset>>3] = buf[offset>>3]&^(1<<shift) | bit<<shift
+		offset++
+	}
+}
+
+// unsignedBitfieldOverflow reports whether value+incr overflows n bits, 1 above and -1
+// below, and the result to store according to overflow, like Redis.
+func unsignedBitfieldOverflow(value uint64, incr int64, n int, overflow int) (uint64, int) {
+	maxValue := uint64(1)<<n - 1
+	maxIncr := int64(maxValue - value)
+	minIncr := -int64(value)
+	wrapped := (value + uint64(incr)) & maxValue
+	switch {
+	case value > maxValue || (incr > 0 && incr > maxIncr):
+		if overflow == bitfieldSat {
+			return maxValue, 1
+		}
+		return wrapped, 1
+	case incr < 0 && incr < minIncr:
+		if overflow == bitfieldSat {
+			return 0, -1
+		}
+		return wrapped, -1
+	}
+	return wrapped, 0
+}
+
+// signedBitfieldOverflow is unsignedBitfieldOverflow for two's complement integers.
+func signedBitfieldOverflow(value, incr int64, n int, overflow int) (int64, int) {
+	maxValue := int64(math.MaxInt64)
+	if n < 64 {
+		maxValue = int64(1)<<(n-1) - 1
+	}
+	minValue := -maxValue - 1
+	// the increments may overflow, they are only used once value is known to be in range
+	maxIncr := maxValue - value
+	minIncr := minValue - value
+
+	// add as unsigned, then sign extend the n bits
+	wrapped := uint64(value) + uint64(incr)
+	if n < 64 {
+		mask := uint64(math.MaxUint64) << n
+		if wrapped&(1<<(n-1)) != 0 {
+			wrapped |= mask
+		} else {
+			wrapped &^= mask
+		}
+	}
+	switch {
+	case value > maxValue || (n != 64 && incr > maxIncr) || (value >= 0 && incr > 0 && incr > maxIncr):
+		if overflow == bitfieldSat {
+			return maxValue, 1
+		}
+		return int64(wrapped), 1
+	case value < minValue || (n != 64 && incr < minIncr) || (value < 0 && incr < 0 && incr < minIncr):
+		if overflow == bitfieldSat {
+			return minValue, -1
+		}
+		return int64(wrapped), -1
+	}
+	return int64(wrapped), 0
+}
+
+// BITFIELD key [GET encoding offset | [OVERFLOW WRAP | SAT | FAIL] SET encoding offset value | INCRBY encoding offset increment ...]
+func cmdBITFIELD(c *Client, args []string) []byte {
+	db := c.db()
+	key := args[0]
+	var ops []bitfieldOp
+	overflow := bitfieldWrap
+	// size is the number of bytes the writes need
+	var size int64
+	for i := 1; i < len(args); i++ {
+		cmd := strings.ToUpper(args[i])
+		remaining := len(args) - i - 1
+		if cmd == "OVERFLOW" && remaining >= 1 {
+			switch strings.ToUpper(args[i+1]) {
+			case "WRAP":
+				overflow = bitfieldWrap
+			case "SAT":
+				overflow = bitfieldSat
+			case "FAIL":
+				overflow = bitfieldFail
+			default:
+				return Encode(errors.New("ERR Invalid OVERFLOW type specified"), false)
+			}
+			i++
+			continue
+		}
+		if !(cmd == "GET" && remaining >= 2) && !((cmd == "SET" || cmd == "INCRBY") && remaining >= 3) {
+			return Encode(errSyntax, false)
+		}
+		signed, n, err := parseBitfieldType(args[i+1])
+		if err != nil {
+			return Encode(err, false)
+		}
+		offset, err := parseBitOffset(args[i+2], true, int64(n))
+		if err != nil {
+			return Encode(err, false)
+		}
+		op := bitfieldOp{cmd: cmd, offset: offset, bits: n, signed: signed, overflow: overflow}
+		i += 2
+		if cmd != "GET" {
+			if op.value, err = strconv.ParseInt(args[i+1], 10, 64); err != nil {
+				return Encode(errNotInteger, false)
+			}
+			size = max(size, (offset+int64(n)-1)>>3+1)
+			i++
+		}
+		ops = append(ops, op)
+	}
+
+	var buf []byte
+	var obj *data_structure.Obj
+	var err error
+	if size > 0 {
+		buf, obj, err = bitmapForWrite(db, key, size)
+	} else {
+		buf, err = lookupBitmap(db, key)
+	}
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]interface{}, 0, len(ops))
+	for _, op := range ops {
+		if op.cmd == "GET" {
+			if op.signed {
+				res = append(res, getSignedBitfield(buf, op.offset, op.bits))
+			} else {
+				res = append(res, int64(getBitfield(buf, op.offset, op.bits)))
+			}
+			continue
+		}
+		var old, value int64
+		var overflowed int
+		if op.signed {
+			old = getSignedBitfield(buf, op.offset, op.bits)
+			if op.cmd == "SET" {
+				value, overflowed = signedBitfieldOverflow(op.value, 0, op.bits, op.overflow)
+			} else {
+				value, overflowed = signedBitfieldOverflow(old, op.value, op.bits, op.overflow)
+			}
+		} else {
+			u := getBitfield(buf, op.offset, op.bits)
+			old = int64(u)
+			var v uint64
+			if op.cmd == "SET" {
+				v, overflowed = unsignedBitfieldOverflow(uint64(op.value), 0, op.bits, op.overflow)
+			} else {
+				v, overflowed = unsignedBitfieldOverflow(u, op.value, op.bits, op.overflow)
+			}
+			value = int64(v)
+		}
+		if overflowed != 0 && op.overflow == bitfieldFail {
+			res = append(res, RespNull{})
+			continue
+		}
+		setBitfield(buf, op.offset, op.bits, uint64(value))
+		if op.cmd == "SET" {
+			res = append(res, old)
+		} else {
+			res = append(res, value)
+		}
+	}
+	if size > 0 {
+		storeBitmap(db, key, obj, buf)
+	}
+	return c.Encode(res)
+}
@@ -8,9 +8,19 @@ import (
 	"strconv"
 )
 
-func cmdBFRESERVE(args []string) []byte {
+// lookupBloom returns the bloom filter stored at key, or nil when the key does not exist.
+func lookupBloom(db *data_structure.Dict, key string) (*data_structure.Bloom, error) {
+	obj, err := lookupKey(db, key, data_structure.ObjTypeBloom)
+	if obj == nil || err != nil {
+		return nil, err
+	}
+	return obj.Value.(*data_structure.Bloom), nil
+}
+
+func cmdBFRESERVE(c *Client, args []string) []byte {
+	db := c.db()
 	if !(len(args) == 3 || len(args) == 5) {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.RESERVE' command"), false)
+		return Encode(errWrongArgs("bf.reserve"), false)
 	}
 	key := args[0]
 	errRate, err := strconv.ParseFloat(args[1], 64)
@@ -21,24 +31,28 @@ func cmdBFRESERVE(args []string) []byte {
 	if err != nil {
 		return Encode(errors.New(fmt.Sprintf("capacity must be an integer number %s", args[2])), false)
 	}
-	_, exist := bloomStore[key]
-	if exist {
+	bloom, err := lookupBloom(db, key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if bloom != nil {
 		return Encode(errors.New(fmt.Sprintf("Bloom filter with key '%s' already exist", key)), false)
 	}
-	bloomStore[key] = data_structure.CreateBloomFilter(capacity, errRate)
+	db.Set(key, db.NewObject(data_structure.CreateBloomFilter(capacity, errRate)))
 	return constant.RespOk
 }
 
-func cmdBFMADD(args []string) []byte {
-	if len(args) < 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.MADD' command"), false)
-	}
+func cmdBFMADD(c *Client, args []string) []byte {
+	db := c.db()
 	key := args[0]
-	bloom, exist := bloomStore[key]
-	if !exist {
+	bloom, err := lookupBloom(db, key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if bloom == nil {
 		bloom = data_structure.CreateBloomFilter(constant.BfDefaultInitCapacity,
 			constant.BfDefaultErrRate)
-		bloomStore[key] = bloom
+		db.Set(key, db.NewObject(bloom))
 	}
 	var res []string
 	for i := 1; i < len(args); i++ {
@@ -49,13 +63,14 @@ func cmdBFMADD(args []string) []byte {
 	return Encode(res, false)
 }
 
-func cmdBFEXISTS(args []string) []byte {
-	if len(args) != 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.EXISTS' command"), false)
-	}
+func cmdBFEXISTS(c *Client, args []string) []byte {
+	db := c.db()
 	key, item := args[0], args[1]
-	bloom, exist := bloomStore[key]
-	if !exist {
+	bloom, err := lookupBloom(db, key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if bloom == nil {
 		return constant.RespZero
 	}
 	if !bloom.Exist(item) {
@@ -9,10 +9,17 @@ import (
 	"strconv"
 )
 
-func cmdCMSINITBYDIM(args []string) []byte {
-	if len(args) != 3 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'CMS.INITBYDIM' command"), false)
+// lookupCMS returns the count-min sketch stored at key, or nil when the key does not exist.
+func lookupCMS(db *data_structure.Dict, key string) (*data_structure.CMS, error) {
+	obj, err := lookupKey(db, key, data_structure.ObjTypeCMS)
+	if obj == nil || err != nil {
+		return nil, err
 	}
+	return obj.Value.(*data_structure.CMS), nil
+}
+
+func cmdCMSINITBYDIM(c *Client, args []string) []byte {
+	db := c.db()
 	key := args[0]
 	width, err := strconv.ParseUint(args[1], 10, 32)
 	if err != nil {
@@ -22,18 +29,19 @@ func cmdCMSINITBYDIM(args []string) []byte {
 	if err != nil {
 		return Encode(fmt.Errorf("height must be a integer number %s", args[1]), false)
 	}
-	_, exist := cmsStore[key]
-	if exist {
+	cms, err := lookupCMS(db, key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cms != nil {
 		return Encode(errors.New("CMS: key already exists"), false)
 	}
-	cmsStore[key] = data_structure.CreateCMS(uint32(width), uint32(height))
+	db.Set(key, db.NewObject(data_structure.CreateCMS(uint32(width), uint32(height))))
 	return constant.RespOk
 }
 
-func cmdCMSINITBYPROB(args []string) []byte {
-	if len(args) != 3 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'CMS.INITBYPROB' command"), false)
-	}
+func cmdCMSINITBYPROB(c *Client, args []string) []byte {
+	db := c.db()
 	key := args[0]
 	errRate, err := strconv.ParseFloat(args[1], 64)
 	if err != nil {
@@ -49,22 +57,29 @@ func cmdCMSINITBYPROB(args []string) []byte {
 	if probability >= 1 || probability <= 0 {
 		return Encode(errors.New("CMS: invalid prob value"), false)
 	}
-	_, exist := cmsStore[key]
-	if exist {
+	cms, err := lookupCMS(db, key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cms != nil {
 		return Encode(errors.New("CMS: key already exists"), false)
 	}
 	w, h := data_structure.CalcCMSDim(errRate, probability)
-	cmsStore[key] = data_structure.CreateCMS(w, h)
+	db.Set(key, db.NewObject(data_structure.CreateCMS(w, h)))
 	return constant.RespOk
 }
 
-func cmdCMSINCRBY(args []string) []byte {
-	if len(args) < 3 || len(args)%2 == 0 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'CMS.INCBY' command"), false)
+func cmdCMSINCRBY(c *Client, args []string) []byte {
+	db := c.db()
+	if len(args)%2 == 0 {
+		return Encode(errWrongArgs("cms.incrby"), false)
 	}
 	key := args[0]
-	cms, exist := cmsStore[key]
-	if !exist {
+	cms, err := lookupCMS(db, key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cms == nil {
 		return Encode(errors.New("CMS: key does not exist"), false)
 	}
 	var res []string
@@ -84,13 +99,14 @@ func cmdCMSINCRBY(args []string) []byte {
 	return Encode(res, false)
 }
 
-func cmdCMSQUERY(args []string) []byte {
-	if len(args) < 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'CMS.QUERY' command"), false)
-	}
+func cmdCMSQUERY(c *Client, args []string) []byte {
+	db := c.db()
 	key := args[0]
-	cms, exist := cmsStore[key]
-	if !exist {
+	cms, err := lookupCMS(db, key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cms == nil {
 		return Encode(errors.New("CMS: key does not exist"), false)
 	}
 	var res []string
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"redis-clone/internal/constant"
+	"strconv"
+	"strings"
+)
+
+// HELLO [protover [AUTH username password] [SETNAME clientname]]
+func cmdHELLO(c *Client, args []string) []byte {
+	proto := c.Proto
+	i := 0
+	if len(args) > 0 {
+		ver, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return Encode(errors.New("ERR Protocol version is not an integer or out of range"), false)
+		}
+		if ver != Resp2 && ver != Resp3 {
+			return Encode(errors.New("NOPROTO unsupported protocol version"), false)
+		}
+		proto = int(ver)
+		i++
+	}
+	name, setName := "", false
+	for ; i < len(args); i++ {
+		remaining := len(args) - i - 1
+		switch {
+		case strings.EqualFold(args[i], "AUTH") && remaining >= 2:
+			// there is no ACL, only the password-less default user exists
+			if args[i+1] != "default" {
+				return Encode(errors.New("WRONGPASS invalid username-password pair or user is disabled."), false)
+			}
+			i += 2
+		case strings.EqualFold(args[i], "SETNAME") && remaining >= 1:
+			name, setName = args[i+1], true
+			if !validClientName(name) {
+				return Encode(errors.New("ERR Client names cannot contain spaces, newlines or special characters."), false)
+			}
+			i++
+		default:
+			return Encode(fmt.Errorf("ERR Syntax error in HELLO option '%s'", args[i]), false)
+		}
+	}
+	c.Proto = proto
+	if setName {
+		c.Name = name
+	}
+	return c.Encode(RespMap{
+		"server", "redis",
+		"version", constant.ServerVersion,
+		"proto", c.Proto,
+		"id", c.ID,
+		"mode", "standalone",
+		"role", "master",
+		"modules", []interface{}{},
+	})
+}
+
+// validClientName reports whether name only has printable characters and no spaces, like Redis requires.
+func validClientName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] < '!' || name[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func cmdSELECT(c *Client, args []string) []byte {
+	id, err := parseDBIndex(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	c.DB = id
+	return constant.RespOk
+}
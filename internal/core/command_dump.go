@@ -0,0 +1,141 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc64"
+	"math"
+	"redis-clone/internal/constant"
+	"redis-clone/internal/data_structure"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dumpVersion is written in every DUMP payload, RESTORE refuses payloads from a newer
+// format it cannot read.
+const dumpVersion uint16 = 1
+
+// crc64Table uses the Jones polynomial, the one Redis checksums its payloads with.
+var crc64Table = crc64.MakeTable(0x95AC9329AC4BC9B5)
+
+var errDumpPayload = errors.New("ERR DUMP payload version or checksum are wrong")
+var errBusyKey = errors.New("BUSYKEY Target key name already exists.")
+
+// dumpPayload serializes value followed by the format version and a CRC64 of both,
+// little endian like in Redis.
+func dumpPayload(value interface{}) []byte {
+	buf := data_structure.SerializeValue(nil, value)
+	buf = binary.LittleEndian.AppendUint16(buf, dumpVersion)
+	return binary.LittleEndian.AppendUint64(buf, crc64.Checksum(buf, crc64Table))
+}
+
+// loadPayload checks the footer of a DUMP payload and decodes the value it holds.
+func loadPayload(key string, payload []byte) (interface{}, error) {
+	if len(payload) < 10 {
+		return nil, errDumpPayload
+	}
+	footer := len(payload) - 10
+	version := binary.LittleEndian.Uint16(payload[footer:])
+	checksum := binary.LittleEndian.Uint64(payload[footer+2:])
+	if version > dumpVersion || checksum != crc64.Checksum(payload[:footer+2], crc64Table) {
+		return nil, errDumpPayload
+	}
+	value, rest, err := data_structure.DeserializeValue(key, payload[:footer])
+	if err != nil || len(rest) != 0 {
+		return nil, data_structure.ErrBadDataFormat
+	}
+	return value, nil
+}
+
+// DUMP key
+func cmdDUMP(c *Client, args []string) []byte {
+	obj := c.db().Get(args[0])
+	if obj == nil {
+		return c.Encode(nil)
+	}
+	return Encode(string(dumpPayload(obj.Value)), false)
+}
+
+// RESTORE key ttl serialized-value [REPLACE] [ABSTTL] [IDLETIME seconds] [FREQ frequency]
+func cmdRESTORE(c *Client, args []string) []byte {
+	db := c.db()
+	key := args[0]
+	replace, absTTL := false, false
+	idle, freq := int64(-1), int64(-1)
+	for i := 3; i < len(args); i++ {
+		switch {
+		case strings.EqualFold(args[i], "REPLACE"):
+			replace = true
+		case strings.EqualFold(args[i], "ABSTTL"):
+			absTTL = true
+		case strings.EqualFold(args[i], "IDLETIME") && i+1 < len(args) && freq == -1:
+			v, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return Encode(errNotInteger, false)
+			}
+			if v < 0 {
+				return Encode(errors.New("ERR Invalid IDLETIME value, must be >= 0"), false)
+			}
+			idle = v
+			i++
+		case strings.EqualFold(args[i], "FREQ") && i+1 < len(args) && idle == -1:
+			v, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return Encode(errNotInteger, false)
+			}
+			if v < 0 || v > 255 {
+				return Encode(errors.New("ERR Invalid FREQ value, must be >= 0 and <= 255"), false)
+			}
+			freq = v
+			i++
+		default:
+			return Encode(errSyntax, false)
+		}
+	}
+	ttl, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	if ttl < 0 {
+		return Encode(errors.New("ERR Invalid TTL value, must be >= 0"), false)
+	}
+	now := time.Now().UnixMilli()
+	if !absTTL && ttl > math.MaxInt64-now {
+		// the deadline would wrap around and expire the key right away
+		return Encode(errors.New("ERR invalid expire time in 'restore' command"), false)
+	}
+	if !replace && db.Get(key) != nil {
+		return Encode(errBusyKey, false)
+	}
+	value, err := loadPayload(key, []byte(args[2]))
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	var expireAt int64
+	if ttl > 0 {
+		expireAt = ttl
+		if !absTTL {
+			expireAt += now
+		}
+		if expireAt <= now {
+			// the key would expire right away, only the replaced one is removed
+			db.Delete(key)
+			return constant.RespOk
+		}
+	}
+	obj := db.NewObject(value)
+	if data_structure.LFUEnabled() {
+		if freq >= 0 {
+			obj.SetFreq(uint8(freq))
+		}
+	} else if idle >= 0 {
+		obj.SetIdleTime(time.Duration(idle) * time.Second)
+	}
+	db.Set(key, obj)
+	if expireAt > 0 {
+		db.SetExpiredAt(key, uint64(expireAt))
+	}
+	return constant.RespOk
+}
@@ -0,0 +1,158 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"redis-clone/internal/constant"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// expireGeneric implements EXPIRE, PEXPIRE, EXPIREAT and PEXPIREAT. args[1] is in
+// seconds unless inMs is set, and relative to now unless absolute is set.
+//
+//	EXPIRE key seconds [NX | XX | GT | LT]
+func expireGeneric(c *Client, args []string, name string, inMs bool, absolute bool) []byte {
+	key := args[0]
+	when, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	var nx, xx, gt, lt bool
+	for _, arg := range args[2:] {
+		switch strings.ToUpper(arg) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		default:
+			return Encode(fmt.Errorf("ERR Unsupported option %s", arg), false)
+		}
+	}
+	if nx && (xx || gt || lt) {
+		return Encode(errors.New("ERR NX and XX, GT or LT options at the same time are not compatible"), false)
+	}
+	if gt && lt {
+		return Encode(errors.New("ERR GT and LT options at the same time are not compatible"), false)
+	}
+
+	errInvalid := fmt.Errorf("ERR invalid expire time in '%s' command", name)
+	if !inMs {
+		if when > math.MaxInt64/1000 || when < math.MinInt64/1000 {
+			return Encode(errInvalid, false)
+		}
+		when *= 1000
+	}
+	if !absolute {
+		now := time.Now().UnixMilli()
+		if when > math.MaxInt64-now {
+			return Encode(errInvalid, false)
+		}
+		when += now
+	}
+
+	db := c.db()
+	if db.Get(key) == nil {
+		return constant.ExpireKeyNotExist
+	}
+	current, hasTTL := db.GetExpired(key)
+	switch {
+	case nx && hasTTL, xx && !hasTTL:
+		return constant.ExpireKeyNotExist
+	// a key without TTL has an infinite one, it is never smaller than when
+	case gt && (!hasTTL || when <= int64(current)):
+		return constant.ExpireKeyNotExist
+	case lt && hasTTL && when >= int64(current):
+		return constant.ExpireKeyNotExist
+	}
+	if when <= time.Now().UnixMilli() {
+		db.Delete(key)
+		return constant.ExpireKeySuccess
+	}
+	db.SetExpiredAt(key, uint64(when))
+	return constant.ExpireKeySuccess
+}
+
+func cmdExpire(c *Client, args []string) []byte {
+	return expireGeneric(c, args, "expire", false, false)
+}
+
+func cmdPEXPIRE(c *Client, args []string) []byte {
+	return expireGeneric(c, args, "pexpire", true, false)
+}
+
+func cmdEXPIREAT(c *Client, args []string) []byte {
+	return expireGeneric(c, args, "expireat", false, true)
+}
+
+func cmdPEXPIREAT(c *Client, args []string) []byte {
+	return expireGeneric(c, args, "pexpireat", true, true)
+}
+
+// ttlGeneric implements TTL and PTTL, replying the remaining time to live of key.
+func ttlGeneric(c *Client, key string, inMs bool) []byte {
+	db := c.db()
+	if db.Get(key) == nil {
+		return constant.TtlKeyNotExist
+	}
+	exp, exist := db.GetExpired(key)
+	if !exist {
+		return constant.TtlKeyExistNoExpire
+	}
+	remainMs := int64(exp) - time.Now().UnixMilli()
+	if remainMs < 0 {
+		remainMs = 0
+	}
+	if inMs {
+		return Encode(remainMs, false)
+	}
+	// rounded like Redis does
+	return Encode((remainMs+500)/1000, false)
+}
+
+func cmdTTL(c *Client, args []string) []byte {
+	return ttlGeneric(c, args[0], false)
+}
+
+func cmdPTTL(c *Client, args []string) []byte {
+	return ttlGeneric(c, args[0], true)
+}
+
+// expireTimeGeneric implements EXPIRETIME and PEXPIRETIME, replying the absolute unix
+// time key expires at.
+func expireTimeGeneric(c *Client, key string, inMs bool) []byte {
+	db := c.db()
+	if db.Get(key) == nil {
+		return constant.TtlKeyNotExist
+	}
+	exp, exist := db.GetExpired(key)
+	if !exist {
+		return constant.TtlKeyExistNoExpire
+	}
+	if inMs {
+		return Encode(int64(exp), false)
+	}
+	return Encode(int64(exp)/1000, false)
+}
+
+func cmdEXPIRETIME(c *Client, args []string) []byte {
+	return expireTimeGeneric(c, args[0], false)
+}
+
+func cmdPEXPIRETIME(c *Client, args []string) []byte {
+	return expireTimeGeneric(c, args[0], true)
+}
+
+func cmdPERSIST(c *Client, args []string) []byte {
+	db := c.db()
+	if db.Get(args[0]) == nil || !db.Persist(args[0]) {
+		return constant.RespZero
+	}
+	return constant.RespOne
+}
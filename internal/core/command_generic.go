@@ -0,0 +1,358 @@
+package core
+
+import (
+	"errors"
+	"redis-clone/internal/constant"
+	"redis-clone/internal/data_structure"
+	"strconv"
+	"strings"
+)
+
+var errNoSuchKey = errors.New("ERR no such key")
+var errSyntax = errors.New("ERR syntax error")
+var errNotInteger = errors.New("ERR value is not an integer or out of range")
+var errSameObject = errors.New("ERR source and destination objects are the same")
+
+// typeName returns the name TYPE reports for an object type.
+func typeName(objType uint8) string {
+	switch objType {
+	case data_structure.ObjTypeString:
+		return "string"
+	case data_structure.ObjTypeSet:
+		return "set"
+	case data_structure.ObjTypeZSet:
+		return "zset"
+	case data_structure.ObjTypeList:
+		return "list"
+	case data_structure.ObjTypeCMS:
+		// the type names used by the RedisBloom module
+		return "CMSk-TYPE"
+	case data_structure.ObjTypeBloom:
+		return "MBbloom--"
+	}
+	return "none"
+}
+
+func cmdTYPE(c *Client, args []string) []byte {
+	db := c.db()
+	obj := db.Get(args[0])
+	if obj == nil {
+		return Encode("none", true)
+	}
+	return Encode(typeName(obj.Type), true)
+}
+
+// renameKey moves the object at src, with its TTL, to dst. When nx is set an existing
+// dst is left alone and false is returned.
+func renameKey(db *data_structure.Dict, src, dst string, nx bool) (bool, error) {
+	obj := db.Get(src)
+	if obj == nil {
+		return false, errNoSuchKey
+	}
+	if src == dst {
+		return !nx, nil
+	}
+	if nx && db.Get(dst) != nil {
+		return false, nil
+	}
+	expireAt, hasTTL := db.GetExpired(src)
+	db.Delete(src)
+	db.Delete(dst)
+	db.Set(dst, obj)
+	if hasTTL {
+		db.SetExpiredAt(dst, expireAt)
+	}
+	return true, nil
+}
+
+func cmdRENAME(c *Client, args []string) []byte {
+	if _, err := renameKey(c.db(), args[0], args[1], false); err != nil {
+		return Encode(err, false)
+	}
+	return constant.RespOk
+}
+
+func cmdRENAMENX(c *Client, args []string) []byte {
+	renamed, err := renameKey(c.db(), args[0], args[1], true)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if !renamed {
+		return constant.RespZero
+	}
+	return constant.RespOne
+}
+
+// COPY source destination [DB destination-db] [REPLACE]
+func cmdCOPY(c *Client, args []string) []byte {
+	db := c.db()
+	dstDB := db
+	src, dst := args[0], args[1]
+	replace := false
+	for i := 2; i < len(args); i++ {
+		switch {
+		case strings.EqualFold(args[i], "REPLACE"):
+			replace = true
+		case strings.EqualFold(args[i], "DB") && i+1 < len(args):
+			id, err := parseDBIndex(args[i+1])
+			if err != nil {
+				return Encode(err, false)
+			}
+			dstDB = dbs[id]
+			i++
+		default:
+			return Encode(errSyntax, false)
+		}
+	}
+	if db == dstDB && src == dst {
+		return Encode(errSameObject, false)
+	}
+	obj := db.Get(src)
+	if obj == nil {
+		return constant.RespZero
+	}
+	if dstDB.Get(dst) != nil {
+		if !replace {
+			return constant.RespZero
+		}
+		dstDB.Delete(dst)
+	}
+	expireAt, hasTTL := db.GetExpired(src)
+	dstDB.Set(dst, dstDB.NewObject(data_structure.DupValue(dst, obj.Value)))
+	if hasTTL {
+		dstDB.SetExpiredAt(dst, expireAt)
+	}
+	return constant.RespOne
+}
+
+func cmdRANDOMKEY(c *Client, args []string) []byte {
+	db := c.db()
+	key, ok := db.RandomKey()
+	if !ok {
+		return c.Encode(nil)
+	}
+	return Encode(key, false)
+}
+
+func cmdTOUCH(c *Client, args []string) []byte {
+	db := c.db()
+	touched := 0
+	for _, key := range args {
+		// a lookup refreshes the access time used by LRU and LFU
+		if db.Get(key) != nil {
+			touched++
+		}
+	}
+	return Encode(touched, false)
+}
+
+func cmdDBSIZE(c *Client, args []string) []byte {
+	return Encode(c.db().Len(), false)
+}
+
+// MOVE key db
+func cmdMOVE(c *Client, args []string) []byte {
+	key := args[0]
+	id, err := parseDBIndex(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if id == c.DB {
+		return Encode(errSameObject, false)
+	}
+	src, dst := c.db(), dbs[id]
+	obj := src.Get(key)
+	if obj == nil || dst.Get(key) != nil {
+		return constant.RespZero
+	}
+	expireAt, hasTTL := src.GetExpired(key)
+	src.Delete(key)
+	dst.Set(key, obj)
+	if hasTTL {
+		dst.SetExpiredAt(key, expireAt)
+	}
+	return constant.RespOne
+}
+
+// SWAPDB index1 index2
+func cmdSWAPDB(c *Client, args []string) []byte {
+	a, err := strconv.Atoi(args[0])
+	if err != nil {
+		return Encode(errors.New("ERR invalid first DB index"), false)
+	}
+	b, err := strconv.Atoi(args[1])
+	if err != nil {
+		return Encode(errors.New("ERR invalid second DB index"), false)
+	}
+	if a < 0 || a >= len(dbs) || b < 0 || b >= len(dbs) {
+		return Encode(errDBIndexOutOfRange, false)
+	}
+	// clients keep the index they selected, so they see the swapped data right away
+	dbs[a], dbs[b] = dbs[b], dbs[a]
+	return constant.RespOk
+}
+
+// parseFlushMode parses the optional ASYNC or SYNC argument of FLUSHDB and FLUSHALL and
+// reports whether the keys are released in the background.
+func parseFlushMode(args []string) (bool, error) {
+	if len(args) > 1 {
+		return false, errSyntax
+	}
+	if len(args) == 0 || strings.EqualFold(args[0], "SYNC") {
+		return false, nil
+	}
+	if strings.EqualFold(args[0], "ASYNC") {
+		return true, nil
+	}
+	return false, errSyntax
+}
+
+func flushDB(db *data_structure.Dict, async bool) {
+	if async {
+		db.FlushAsync()
+	} else {
+		db.Flush()
+	}
+}
+
+func cmdFLUSHDB(c *Client, args []string) []byte {
+	async, err := parseFlushMode(args)
+	if err != nil {
+		return Encode(err, false)
+	}
+	flushDB(c.db(), async)
+	return constant.RespOk
+}
+
+func cmdFLUSHALL(c *Client, args []string) []byte {
+	async, err := parseFlushMode(args)
+	if err != nil {
+		return Encode(err, false)
+	}
+	for _, db := range dbs {
+		flushDB(db, async)
+	}
+	return constant.RespOk
+}
+
+// scanOptions holds the arguments shared by SCAN, SSCAN and ZSCAN.
+type scanOptions struct {
+	cursor uint64
+	// pattern is empty when every element matches
+	pattern string
+	count   int
+	// objType is empty when keys of every type are returned
+	objType string
+}
+
+// parseScanArgs parses "cursor [MATCH pattern] [COUNT count] [TYPE type]", TYPE is only
+// accepted for the keyspace SCAN.
+func parseScanArgs(args []string, allowType bool) (*scanOptions, error) {
+	cursor, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return nil, errors.New("ERR invalid cursor")
+	}
+	opts := &scanOptions{cursor: cursor, count: 10}
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return nil, errSyntax
+		}
+		switch {
+		case strings.EqualFold(args[i], "MATCH"):
+			opts.pattern = args[i+1]
+			if opts.pattern == "*" {
+				opts.pattern = ""
+			}
+		case strings.EqualFold(args[i], "COUNT"):
+			count, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return nil, errNotInteger
+			}
+			if count < 1 {
+				return nil, errSyntax
+			}
+			opts.count = count
+		case allowType && strings.EqualFold(args[i], "TYPE"):
+			opts.objType = args[i+1]
+		default:
+			return nil, errSyntax
+		}
+	}
+	return opts, nil
+}
+
+// scanLoop calls step on successive cursors until produced reports count elements, the
+// cursor wraps around to 0, or count*10 buckets were visited, like the SCAN loop in Redis.
+// It returns the cursor the client has to send next.
+func scanLoop(opts *scanOptions, step func(cursor uint64) uint64, produced func() int) uint64 {
+	cursor := opts.cursor
+	maxIterations := opts.count * 10
+	for {
+		cursor = step(cursor)
+		maxIterations--
+		if cursor == 0 || maxIterations <= 0 || produced() >= opts.count {
+			return cursor
+		}
+	}
+}
+
+func scanReply(c *Client, cursor uint64, elements []string) []byte {
+	return c.Encode([]interface{}{strconv.FormatUint(cursor, 10), elements})
+}
+
+func cmdKEYS(c *Client, args []string) []byte {
+	db := c.db()
+	pattern := args[0]
+	allKeys := pattern == "*"
+	keys := []string{}
+	var expired []string
+	db.GetDictStore().ForEach(func(key string, _ *data_structure.Obj) bool {
+		if !allKeys && !stringMatch(pattern, key, false) {
+			return true
+		}
+		if db.HasExpired(key) {
+			expired = append(expired, key)
+			return true
+		}
+		keys = append(keys, key)
+		return true
+	})
+	for _, key := range expired {
+		db.ExpireIfNeeded(key)
+	}
+	return Encode(keys, false)
+}
+
+// SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+func cmdSCAN(c *Client, args []string) []byte {
+	db := c.db()
+	opts, err := parseScanArgs(args, true)
+	if err != nil {
+		return Encode(err, false)
+	}
+	type scanned struct {
+		key string
+		obj *data_structure.Obj
+	}
+	var batch []scanned
+	cursor := scanLoop(opts, func(cursor uint64) uint64 {
+		return db.Scan(cursor, func(key string, obj *data_structure.Obj) {
+			batch = append(batch, scanned{key, obj})
+		})
+	}, func() int { return len(batch) })
+
+	keys := []string{}
+	for _, e := range batch {
+		if opts.pattern != "" && !stringMatch(opts.pattern, e.key, false) {
+			continue
+		}
+		if db.ExpireIfNeeded(e.key) {
+			continue
+		}
+		if opts.objType != "" && !strings.EqualFold(opts.objType, typeName(e.obj.Type)) {
+			continue
+		}
+		keys = append(keys, e.key)
+	}
+	return scanReply(c, cursor, keys)
+}
@@ -0,0 +1,412 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"redis-clone/internal/constant"
+	"redis-clone/internal/data_structure"
+	"strconv"
+	"strings"
+)
+
+var errNotPositive = errors.New("ERR value is out of range, must be positive")
+var errListIndex = errors.New("ERR index out of range")
+
+// lookupList returns the list stored at key, or nil when the key does not exist.
+func lookupList(db *data_structure.Dict, key string) (*data_structure.Quicklist, error) {
+	obj, err := lookupKey(db, key, data_structure.ObjTypeList)
+	if obj == nil || err != nil {
+		return nil, err
+	}
+	return obj.Value.(*data_structure.Quicklist), nil
+}
+
+// pushGeneric adds the elements of args to the head or tail of the list at args[0], the
+// X variants only when the list already exists. It returns the new length.
+func pushGeneric(c *Client, args []string, head bool, xx bool) []byte {
+	db := c.db()
+	key := args[0]
+	list, err := lookupList(db, key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		if xx {
+			return constant.RespZero
+		}
+		list = data_structure.NewQuicklist()
+		db.Set(key, db.NewObject(list))
+	}
+	for _, value := range args[1:] {
+		if head {
+			list.PushHead(value)
+		} else {
+			list.PushTail(value)
+		}
+	}
+	return Encode(int64(list.Len()), false)
+}
+
+func cmdLPUSH(c *Client, args []string) []byte {
+	return pushGeneric(c, args, true, false)
+}
+
+func cmdRPUSH(c *Client, args []string) []byte {
+	return pushGeneric(c, args, false, false)
+}
+
+func cmdLPUSHX(c *Client, args []string) []byte {
+	return pushGeneric(c, args, true, true)
+}
+
+func cmdRPUSHX(c *Client, args []string) []byte {
+	return pushGeneric(c, args, false, true)
+}
+
+// popGeneric implements LPOP and RPOP key [count]. Without count it replies with one
+// element, with it an array of up to count elements.
+func popGeneric(c *Client, args []string, head bool) []byte {
+	if len(args) > 2 {
+		return Encode(errSyntax, false)
+	}
+	count := int64(-1)
+	if len(args) == 2 {
+		n, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || n < 0 {
+			return Encode(errNotPositive, false)
+		}
+		count = n
+	}
+	db := c.db()
+	key := args[0]
+	list, err := lookupList(db, key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		if count >= 0 {
+			return c.Encode(RespNullArray{})
+		}
+		return c.Encode(nil)
+	}
+	pop := list.PopTail
+	if head {
+		pop = list.PopHead
+	}
+	var res []byte
+	if count < 0 {
+		value, _ := pop()
+		res = Encode(value, false)
+	} else {
+		values := make([]string, 0, min(count, int64(list.Len())))
+		for ; count > 0 && list.Len() > 0; count-- {
+			value, _ := pop()
+			values = append(values, value)
+		}
+		res = c.Encode(values)
+	}
+	// like Redis, a list that became empty no longer exists
+	if list.Len() == 0 {
+		db.Delete(key)
+	}
+	return res
+}
+
+func cmdLPOP(c *Client, args []string) []byte {
+	return popGeneric(c, args, true)
+}
+
+func cmdRPOP(c *Client, args []string) []byte {
+	return popGeneric(c, args, false)
+}
+
+func cmdLLEN(c *Client, args []string) []byte {
+	list, err := lookupList(c.db(), args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return constant.RespZero
+	}
+	return Encode(int64(list.Len()), false)
+}
+
+// LRANGE key start stop
+func cmdLRANGE(c *Client, args []string) []byte {
+	start, err1 := strconv.ParseInt(args[1], 10, 64)
+	end, err2 := strconv.ParseInt(args[2], 10, 64)
+	if err1 != nil || err2 != nil {
+		return Encode(errNotInteger, false)
+	}
+	list, err := lookupList(c.db(), args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return c.Encode([]string{})
+	}
+	start, end, ok := clampRange(start, end, int64(list.Len()))
+	if !ok {
+		return c.Encode([]string{})
+	}
+	values := make([]string, 0, end-start+1)
+	list.Range(int(start), int(end), func(value string) bool {
+		values = append(values, value)
+		return true
+	})
+	return c.Encode(values)
+}
+
+// LINDEX key index
+func cmdLINDEX(c *Client, args []string) []byte {
+	index, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	list, err := lookupList(c.db(), args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return c.Encode(nil)
+	}
+	value, ok := list.Index(int(index))
+	if !ok {
+		return c.Encode(nil)
+	}
+	return Encode(value, false)
+}
+
+// LSET key index element
+func cmdLSET(c *Client, args []string) []byte {
+	index, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	list, err := lookupList(c.db(), args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return Encode(errNoSuchKey, false)
+	}
+	if !list.Set(int(index), args[2]) {
+		return Encode(errListIndex, false)
+	}
+	return constant.RespOk
+}
+
+// LINSERT key BEFORE | AFTER pivot element
+func cmdLINSERT(c *Client, args []string) []byte {
+	var after bool
+	switch strings.ToUpper(args[1]) {
+	case "BEFORE":
+	case "AFTER":
+		after = true
+	default:
+		return Encode(errSyntax, false)
+	}
+	list, err := lookupList(c.db(), args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return constant.RespZero
+	}
+	if !list.Insert(args[2], args[3], after) {
+		return Encode(int64(-1), false)
+	}
+	return Encode(int64(list.Len()), false)
+}
+
+// LREM key count element
+func cmdLREM(c *Client, args []string) []byte {
+	count, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	db := c.db()
+	key := args[0]
+	list, err := lookupList(db, key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return constant.RespZero
+	}
+	removed := list.Remove(args[2], int(count))
+	if list.Len() == 0 {
+		db.Delete(key)
+	}
+	return Encode(int64(removed), false)
+}
+
+// LTRIM key start stop
+func cmdLTRIM(c *Client, args []string) []byte {
+	start, err1 := strconv.ParseInt(args[1], 10, 64)
+	end, err2 := strconv.ParseInt(args[2], 10, 64)
+	if err1 != nil || err2 != nil {
+		return Encode(errNotInteger, false)
+	}
+	db := c.db()
+	key := args[0]
+	list, err := lookupList(db, key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return constant.RespOk
+	}
+	start, end, ok := clampRange(start, end, int64(list.Len()))
+	list.Trim(int(start), int(end), ok)
+	if list.Len() == 0 {
+		db.Delete(key)
+	}
+	return constant.RespOk
+}
+
+// LPOS key element [RANK rank] [COUNT num-matches] [MAXLEN len]
+func cmdLPOS(c *Client, args []string) []byte {
+	rank, count, maxLen := int64(1), int64(-1), int64(0)
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return Encode(errSyntax, false)
+		}
+		n, err := strconv.ParseInt(args[i+1], 10, 64)
+		if err != nil {
+			return Encode(errNotInteger, false)
+		}
+		switch strings.ToUpper(args[i]) {
+		case "RANK":
+			// like Redis, the most negative rank is refused, it has no positive counterpart
+			if n == math.MinInt64 {
+				return Encode(errors.New("ERR value is out of range, value must between -9223372036854775807 and 9223372036854775807"), false)
+			}
+			if n == 0 {
+				return Encode(errors.New("ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list"), false)
+			}
+			rank = n
+		case "COUNT":
+			if n < 0 {
+				return Encode(errors.New("ERR COUNT can't be negative"), false)
+			}
+			count = n
+		case "MAXLEN":
+			if n < 0 {
+				return Encode(errors.New("ERR MAXLEN can't be negative"), false)
+			}
+			maxLen = n
+		default:
+			return Encode(errSyntax, false)
+		}
+	}
+	list, err := lookupList(c.db(), args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		if count >= 0 {
+			return c.Encode([]interface{}{})
+		}
+		return c.Encode(nil)
+	}
+
+	// a negative rank searches from the tail, skipping the first -rank-1 matches
+	reverse := rank < 0
+	if reverse {
+		rank = -rank
+	}
+	want := count
+	if want < 0 {
+		want = 1
+	}
+	var matches, scanned int64
+	res := []interface{}{}
+	list.ForEach(reverse, func(index int, value string) bool {
+		if maxLen > 0 && scanned == maxLen {
+			return false
+		}
+		scanned++
+		if value != args[1] {
+			return true
+		}
+		matches++
+		if matches >= rank {
+			res = append(res, int64(index))
+		}
+		// COUNT 0 asks for every match
+		return want == 0 || int64(len(res)) < want
+	})
+	if count < 0 {
+		if len(res) == 0 {
+			return c.Encode(nil)
+		}
+		return Encode(res[0], false)
+	}
+	return c.Encode(res)
+}
+
+// moveGeneric pops an element from the head or tail of source and pushes it to the
+// head or tail of destination, both may be the same list.
+func moveGeneric(c *Client, src, dst string, fromHead, toHead bool) []byte {
+	db := c.db()
+	from, err := lookupList(db, src)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if from == nil {
+		return c.Encode(nil)
+	}
+	// the destination is checked before anything is popped
+	to, err := lookupList(db, dst)
+	if err != nil {
+		return Encode(err, false)
+	}
+	var value string
+	if fromHead {
+		value, _ = from.PopHead()
+	} else {
+		value, _ = from.PopTail()
+	}
+	if to == nil {
+		to = data_structure.NewQuicklist()
+		db.Set(dst, db.NewObject(to))
+	}
+	if toHead {
+		to.PushHead(value)
+	} else {
+		to.PushTail(value)
+	}
+	if from.Len() == 0 {
+		db.Delete(src)
+	}
+	return Encode(value, false)
+}
+
+// parseListSide parses the LEFT or RIGHT argument of LMOVE, true meaning the head.
+func parseListSide(arg string) (bool, error) {
+	switch strings.ToUpper(arg) {
+	case "LEFT":
+		return true, nil
+	case "RIGHT":
+		return false, nil
+	}
+	return false, errSyntax
+}
+
+// LMOVE source destination LEFT | RIGHT LEFT | RIGHT
+func cmdLMOVE(c *Client, args []string) []byte {
+	fromHead, err := parseListSide(args[2])
+	if err != nil {
+		return Encode(err, false)
+	}
+	toHead, err := parseListSide(args[3])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return moveGeneric(c, args[0], args[1], fromHead, toHead)
+}
+
+func cmdRPOPLPUSH(c *Client, args []string) []byte {
+	return moveGeneric(c, args[0], args[1], false, true)
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"fmt"
+	"redis-clone/internal/config"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// MEMORY USAGE key [SAMPLES count] | STATS | HELP
+func cmdMEMORY(c *Client, args []string) []byte {
+	switch strings.ToUpper(args[0]) {
+	case "HELP":
+		return Encode([]string{
+			"MEMORY <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+			"STATS",
+			"    Return information about the memory usage of the server.",
+			"USAGE <key> [SAMPLES <count>]",
+			"    Return memory in bytes used by <key> and its value. Nested values keep an",
+			"    exact count of their bytes, the count is accepted and not needed.",
+		}, false)
+	case "USAGE":
+		if len(args) != 2 && len(args) != 4 {
+			return Encode(fmt.Errorf("ERR unknown subcommand or wrong number of arguments for '%s'. Try MEMORY HELP.", args[0]), false)
+		}
+		if len(args) == 4 {
+			if !strings.EqualFold(args[2], "SAMPLES") {
+				return Encode(errSyntax, false)
+			}
+			// aggregates keep an exact count of their bytes, there is nothing to sample
+			if samples, err := strconv.ParseInt(args[3], 10, 64); err != nil || samples < 0 {
+				return Encode(errNotInteger, false)
+			}
+		}
+		size, ok := c.db().MemoryUsage(args[1])
+		if !ok {
+			return c.Encode(nil)
+		}
+		return Encode(size, false)
+	case "STATS":
+		if len(args) != 1 {
+			return Encode(fmt.Errorf("ERR unknown subcommand or wrong number of arguments for '%s'. Try MEMORY HELP.", args[0]), false)
+		}
+		return c.Encode(memoryStats())
+	default:
+		return Encode(fmt.Errorf("ERR unknown subcommand '%s'. Try MEMORY HELP.", args[0]), false)
+	}
+}
+
+// memoryStats builds the reply of MEMORY STATS, using the field names of Redis.
+func memoryStats() RespMap {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	used := usedMemory()
+	peak := max(evictStats.peakMemory, used)
+
+	res := RespMap{
+		"peak.allocated", peak,
+		"total.allocated", used,
+		"allocator.allocated", int64(ms.HeapAlloc),
+		"maxmemory", config.MaxMemory,
+	}
+	var overhead int64
+	keys := 0
+	for i, db := range dbs {
+		if db.Len() == 0 {
+			continue
+		}
+		main, expires := db.MemoryOverhead()
+		overhead += main + expires
+		keys += db.Len()
+		res = append(res, fmt.Sprintf("db.%d", i), RespMap{
+			"overhead.hashtable.main", main,
+			"overhead.hashtable.expires", expires,
+		})
+	}
+	dataset := used - overhead
+	res = append(res,
+		"overhead.total", overhead,
+		"keys.count", keys,
+		"keys.bytes-per-key", used/int64(max(keys, 1)),
+		"dataset.bytes", dataset,
+		"dataset.percentage", RespDouble(percentage(dataset, used)),
+		"peak.percentage", RespDouble(percentage(used, peak)),
+	)
+	return res
+}
+
+func percentage(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
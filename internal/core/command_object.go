@@ -0,0 +1,66 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"redis-clone/internal/constant"
+	"redis-clone/internal/data_structure"
+	"strings"
+	"time"
+)
+
+// OBJECT ENCODING key | REFCOUNT key | IDLETIME key | FREQ key | HELP
+func cmdOBJECT(c *Client, args []string) []byte {
+	sub := strings.ToUpper(args[0])
+	if sub == "HELP" && len(args) == 1 {
+		return Encode([]string{
+			"OBJECT <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+			"ENCODING <key>",
+			"    Return the kind of internal representation used in order to store the value",
+			"    associated with a <key>.",
+			"FREQ <key>",
+			"    Return the access frequency index of the key <key>.",
+			"IDLETIME <key>",
+			"    Return the idle time of the key <key>.",
+			"REFCOUNT <key>",
+			"    Return the number of references of the value associated with the specified",
+			"    <key>.",
+		}, false)
+	}
+	if len(args) != 2 {
+		return Encode(fmt.Errorf("ERR unknown subcommand or wrong number of arguments for '%s'. Try OBJECT HELP.", args[0]), false)
+	}
+	// inspecting an object does not count as an access
+	obj := c.db().GetNoTouch(args[1])
+	switch sub {
+	case "ENCODING":
+		if obj == nil {
+			return c.Encode(nil)
+		}
+		return Encode(data_structure.EncodingName(obj.Encoding()), false)
+	case "REFCOUNT":
+		if obj == nil {
+			return c.Encode(nil)
+		}
+		// values are never shared between keys
+		return constant.RespOne
+	case "IDLETIME":
+		if obj == nil {
+			return c.Encode(nil)
+		}
+		if data_structure.LFUEnabled() {
+			return Encode(errors.New("ERR An LFU maxmemory policy is selected, idle time not tracked. Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust."), false)
+		}
+		return Encode(int64(obj.IdleTime()/time.Second), false)
+	case "FREQ":
+		if obj == nil {
+			return c.Encode(nil)
+		}
+		if !data_structure.LFUEnabled() {
+			return Encode(errors.New("ERR An LFU maxmemory policy is not selected, access frequency not tracked. Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust."), false)
+		}
+		return Encode(int64(obj.Freq()), false)
+	default:
+		return Encode(fmt.Errorf("ERR unknown subcommand '%s'. Try OBJECT HELP.", args[0]), false)
+	}
+}
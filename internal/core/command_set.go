@@ -1,58 +1,108 @@
 package core
 
 import (
-	"errors"
 	"redis-clone/internal/data_structure"
 )
 
-func cmdSADD(args []string) []byte {
-	if len(args) < 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'SADD' command"), false)
+// lookupSet returns the set stored at key, or nil when the key does not exist.
+func lookupSet(db *data_structure.Dict, key string) (*data_structure.SimpleSet, error) {
+	obj, err := lookupKey(db, key, data_structure.ObjTypeSet)
+	if obj == nil || err != nil {
+		return nil, err
 	}
-	key := args[0] // TODO: check key is used by other types or not
-	set, exist := setStore[key]
-	if !exist {
+	return obj.Value.(*data_structure.SimpleSet), nil
+}
+
+func cmdSADD(c *Client, args []string) []byte {
+	db := c.db()
+	key := args[0]
+	set, err := lookupSet(db, key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
 		set = data_structure.NewSimpleSet(key)
-		setStore[key] = set
+		db.Set(key, db.NewObject(set))
 	}
 	count := set.Add(args[1:]...)
 	return Encode(count, false)
 }
 
-func cmdSREM(args []string) []byte {
-	if len(args) < 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'SADD' command"), false)
-	}
+func cmdSREM(c *Client, args []string) []byte {
+	db := c.db()
 	key := args[0]
-	set, exist := setStore[key]
-	if !exist {
-		set = data_structure.NewSimpleSet(key)
-		setStore[key] = set
+	set, err := lookupSet(db, key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
+		return Encode(0, false)
 	}
 	count := set.Rem(args[1:]...)
+	// like Redis, a set that became empty no longer exists
+	if set.Len() == 0 {
+		db.Delete(key)
+	}
 	return Encode(count, false)
 }
 
-func cmdSMEMBERS(args []string) []byte {
-	if len(args) != 1 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'SMEMBERS' command"), false)
-	}
+func cmdSMEMBERS(c *Client, args []string) []byte {
+	db := c.db()
 	key := args[0]
-	set, exist := setStore[key]
-	if !exist {
-		return Encode(make([]string, 0), false)
+	set, err := lookupSet(db, key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
+		return c.Encode(RespSet{})
 	}
-	return Encode(set.Members(), false)
+	members := set.Members()
+	res := make(RespSet, len(members))
+	for i, m := range members {
+		res[i] = m
+	}
+	return c.Encode(res)
 }
 
-func cmdSISMEMBER(args []string) []byte {
-	if len(args) != 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'SISMEMBER' command"), false)
-	}
+func cmdSISMEMBER(c *Client, args []string) []byte {
+	db := c.db()
 	key := args[0]
-	set, exist := setStore[key]
-	if !exist {
+	set, err := lookupSet(db, key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
 		return Encode(0, false)
 	}
 	return Encode(set.IsMember(args[1]), false)
 }
+
+// SSCAN key cursor [MATCH pattern] [COUNT count]
+func cmdSSCAN(c *Client, args []string) []byte {
+	db := c.db()
+	opts, err := parseScanArgs(args[1:], false)
+	if err != nil {
+		return Encode(err, false)
+	}
+	set, err := lookupSet(db, args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
+		return scanReply(c, 0, []string{})
+	}
+	var batch []string
+	cursor := scanLoop(opts, func(cursor uint64) uint64 {
+		return set.Scan(cursor, func(member string) {
+			batch = append(batch, member)
+		})
+	}, func() int { return len(batch) })
+
+	members := []string{}
+	for _, member := range batch {
+		if opts.pattern == "" || stringMatch(opts.pattern, member, false) {
+			members = append(members, member)
+		}
+	}
+	return scanReply(c, cursor, members)
+}
@@ -2,38 +2,53 @@ package core
 
 import (
 	"errors"
-	"fmt"
 	"redis-clone/internal/constant"
 	"redis-clone/internal/data_structure"
 	"strconv"
 )
 
-func cmdZADD(args []string) []byte {
-	if len(args) < 3 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZADD' command"), false)
+// lookupZSet returns the sorted set stored at key, or nil when the key does not exist.
+func lookupZSet(db *data_structure.Dict, key string) (*data_structure.SortedSet, error) {
+	obj, err := lookupKey(db, key, data_structure.ObjTypeZSet)
+	if obj == nil || err != nil {
+		return nil, err
 	}
+	return obj.Value.(*data_structure.SortedSet), nil
+}
+
+func cmdZADD(c *Client, args []string) []byte {
+	db := c.db()
 	key := args[0]
 	scoreIndex := 1
 
 	numScoreEleArgs := len(args) - scoreIndex
 	if numScoreEleArgs%2 == 1 || numScoreEleArgs == 0 {
-		return Encode(fmt.Errorf("(error) Wrong number of (score, member) arg: %d", numScoreEleArgs), false)
+		return Encode(errSyntax, false)
 	}
 
-	zset, exist := zsetStore[key]
-	if !exist {
+	// parse every score first so a bad one leaves the key untouched
+	scores := make([]float64, 0, numScoreEleArgs/2)
+	for i := scoreIndex; i < len(args); i += 2 {
+		score, err := strconv.ParseFloat(args[i], 64)
+		if err != nil {
+			return Encode(errors.New("(error) Score must be floating point number"), false)
+		}
+		scores = append(scores, score)
+	}
+
+	zset, err := lookupZSet(db, key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
 		zset = data_structure.NewSortedSet(constant.DefaultBPlusTreeDegree)
-		zsetStore[key] = zset
+		db.Set(key, db.NewObject(zset))
 	}
 
 	count := 0
 	for i := scoreIndex; i < len(args); i += 2 {
 		member := args[i+1]
-		score, err := strconv.ParseFloat(args[i], 64)
-		if err != nil {
-			return Encode(errors.New("(error) Score must be floating point number"), false)
-		}
-		ret := zset.Add(score, member)
+		ret := zset.Add(scores[(i-scoreIndex)/2], member)
 		if ret != 1 {
 			return Encode(errors.New("error when adding element"), false)
 		}
@@ -42,31 +57,63 @@ func cmdZADD(args []string) []byte {
 	return Encode(count, false)
 }
 
-func cmdZSCORE(args []string) []byte {
-	if len(args) != 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZSCORE' command"), false)
-	}
+func cmdZSCORE(c *Client, args []string) []byte {
+	db := c.db()
 	key, member := args[0], args[1]
-	zset, exist := zsetStore[key]
-	if !exist {
-		return constant.RespNil
+	zset, err := lookupZSet(db, key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
+		return c.Encode(nil)
 	}
 	score, exist := zset.GetScore(member)
 	if !exist {
-		return constant.RespNil
+		return c.Encode(nil)
 	}
-	return Encode(fmt.Sprintf("%f", score), false)
+	return c.Encode(RespDouble(score))
 }
 
-func cmdZRANK(args []string) []byte {
-	if len(args) != 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZRANK' command"), false)
-	}
+func cmdZRANK(c *Client, args []string) []byte {
+	db := c.db()
 	key, member := args[0], args[1]
-	zset, exist := zsetStore[key]
-	if !exist {
-		return constant.RespNil
+	zset, err := lookupZSet(db, key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
+		return c.Encode(nil)
 	}
 	rank := zset.GetRank(member)
 	return Encode(rank, false)
 }
+
+// ZSCAN key cursor [MATCH pattern] [COUNT count]
+func cmdZSCAN(c *Client, args []string) []byte {
+	db := c.db()
+	opts, err := parseScanArgs(args[1:], false)
+	if err != nil {
+		return Encode(err, false)
+	}
+	zset, err := lookupZSet(db, args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
+		return scanReply(c, 0, []string{})
+	}
+	var batch []string
+	cursor := scanLoop(opts, func(cursor uint64) uint64 {
+		return zset.Scan(cursor, func(member string, score float64) {
+			batch = append(batch, member, FormatDouble(score))
+		})
+	}, func() int { return len(batch) / 2 })
+
+	items := []string{}
+	for i := 0; i < len(batch); i += 2 {
+		if opts.pattern == "" || stringMatch(opts.pattern, batch[i], false) {
+			items = append(items, batch[i], batch[i+1])
+		}
+	}
+	return scanReply(c, cursor, items)
+}
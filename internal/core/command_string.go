@@ -0,0 +1,514 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"redis-clone/internal/config"
+	"redis-clone/internal/constant"
+	"redis-clone/internal/data_structure"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errNotFloat = errors.New("ERR value is not a valid float")
+
+// incrDecr adds incr to the integer at key, a missing key counts as 0. The integer is
+// updated in place, so the key keeps its TTL.
+func incrDecr(c *Client, key string, incr int64) []byte {
+	db := c.db()
+	obj, err := lookupKey(db, key, data_structure.ObjTypeString)
+	if err != nil {
+		return Encode(err, false)
+	}
+	var value int64
+	if obj != nil {
+		v, isInt := data_structure.IntValue(obj.Value)
+		if !isInt {
+			return Encode(errNotInteger, false)
+		}
+		value = v
+	}
+	if (incr < 0 && value < 0 && incr < math.MinInt64-value) ||
+		(incr > 0 && value > 0 && incr > math.MaxInt64-value) {
+		return Encode(errors.New("ERR increment or decrement would overflow"), false)
+	}
+	value += incr
+	if obj != nil {
+		obj.Value = value
+	} else {
+		db.Set(key, db.NewObject(value))
+	}
+	return Encode(value, false)
+}
+
+func cmdINCR(c *Client, args []string) []byte {
+	return incrDecr(c, args[0], 1)
+}
+
+func cmdDECR(c *Client, args []string) []byte {
+	return incrDecr(c, args[0], -1)
+}
+
+// INCRBY key increment
+func cmdINCRBY(c *Client, args []string) []byte {
+	incr, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	return incrDecr(c, args[0], incr)
+}
+
+// DECRBY key decrement
+func cmdDECRBY(c *Client, args []string) []byte {
+	decr, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	if decr == math.MinInt64 {
+		return Encode(errors.New("ERR decrement would overflow"), false)
+	}
+	return incrDecr(c, args[0], -decr)
+}
+
+// parseFloatValue parses a float the way INCRBYFLOAT accepts it, NaN is refused.
+func parseFloatValue(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) {
+		return 0, errNotFloat
+	}
+	return v, nil
+}
+
+// formatFloatValue formats a float like Redis does for INCRBYFLOAT, without an exponent
+// and without trailing zeros.
+func formatFloatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// INCRBYFLOAT key increment
+func cmdINCRBYFLOAT(c *Client, args []string) []byte {
+	db := c.db()
+	key := args[0]
+	obj, err := lookupKey(db, key, data_structure.ObjTypeString)
+	if err != nil {
+		return Encode(err, false)
+	}
+	incr, err := parseFloatValue(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	var value float64
+	if obj != nil {
+		value, err = parseFloatValue(data_structure.StringValue(obj.Value))
+		if err != nil {
+			return Encode(err, false)
+		}
+	}
+	value += incr
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return Encode(errors.New("ERR increment would produce NaN or Infinity"), false)
+	}
+	// like Redis the result is stored as a string, even when it is integral
+	res := formatFloatValue(value)
+	if obj != nil {
+		obj.Value = res
+	} else {
+		db.Set(key, db.NewObject(res))
+	}
+	return Encode(res, false)
+}
+
+var errStringTooLong = errors.New("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+
+// checkStringLength refuses to grow a string past config.ProtoMaxBulkLen, a client could
+// not send a longer one either.
+func checkStringLength(size int64) error {
+	if size > config.ProtoMaxBulkLen {
+		return errStringTooLong
+	}
+	return nil
+}
+
+// APPEND key value
+func cmdAPPEND(c *Client, args []string) []byte {
+	db := c.db()
+	key, value := args[0], args[1]
+	obj, err := lookupKey(db, key, data_structure.ObjTypeString)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if obj == nil {
+		db.Set(key, db.NewObject(data_structure.NewString(value)))
+		return Encode(len(value), false)
+	}
+	if err := checkStringLength(int64(data_structure.StringLen(obj.Value)) + int64(len(value))); err != nil {
+		return Encode(err, false)
+	}
+	// appended in place, the spare capacity makes building a log with APPEND linear
+	buf := append(data_structure.MutableString(obj.Value, 0), value...)
+	obj.Value = buf
+	return Encode(len(buf), false)
+}
+
+// STRLEN key
+func cmdSTRLEN(c *Client, args []string) []byte {
+	obj, err := lookupKey(c.db(), args[0], data_structure.ObjTypeString)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if obj == nil {
+		return constant.RespZero
+	}
+	return Encode(data_structure.StringLen(obj.Value), false)
+}
+
+// GETRANGE key start end
+func cmdGETRANGE(c *Client, args []string) []byte {
+	start, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	end, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	obj, err := lookupKey(c.db(), args[0], data_structure.ObjTypeString)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if obj == nil {
+		return Encode("", false)
+	}
+	s := data_structure.StringBytes(obj.Value)
+	start, end, ok := clampRange(start, end, int64(len(s)))
+	if !ok {
+		return Encode("", false)
+	}
+	return Encode(string(s[start:end+1]), false)
+}
+
+// clampRange converts the inclusive range start end, where negative offsets count from
+// the end, to offsets within n elements. It returns false when the range is empty.
+func clampRange(start, end, n int64) (int64, int64, bool) {
+	if start < 0 && end < 0 && start > end {
+		return 0, 0, false
+	}
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if end < 0 {
+		end = max(n+end, 0)
+	}
+	end = min(end, n-1)
+	if start > end || n == 0 {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// SETRANGE key offset value
+func cmdSETRANGE(c *Client, args []string) []byte {
+	db := c.db()
+	key, value := args[0], args[2]
+	offset, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	if offset < 0 {
+		return Encode(errors.New("ERR offset is out of range"), false)
+	}
+	obj, err := lookupKey(db, key, data_structure.ObjTypeString)
+	if err != nil {
+		return Encode(err, false)
+	}
+	var current interface{}
+	if obj != nil {
+		current = obj.Value
+	}
+	// an empty value changes nothing, not even creating the key
+	if len(value) == 0 {
+		if obj == nil {
+			return constant.RespZero
+		}
+		return Encode(data_structure.StringLen(current), false)
+	}
+	if err := checkStringLength(offset + int64(len(value))); err != nil {
+		return Encode(err, false)
+	}
+	// written in place, the gap is padded with zero bytes
+	buf := data_structure.MutableString(current, int(offset)+len(value))
+	copy(buf[offset:], value)
+	if obj == nil {
+		db.Set(key, db.NewObject(buf))
+	} else {
+		obj.Value = buf
+	}
+	return Encode(len(buf), false)
+}
+
+// GETSET key value
+func cmdGETSET(c *Client, args []string) []byte {
+	return cmdSet(c, []string{args[0], args[1], "GET"})
+}
+
+// GETDEL key
+func cmdGETDEL(c *Client, args []string) []byte {
+	db := c.db()
+	obj, err := lookupKey(db, args[0], data_structure.ObjTypeString)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if obj == nil {
+		return c.Encode(nil)
+	}
+	db.Delete(args[0])
+	return Encode(data_structure.StringValue(obj.Value), false)
+}
+
+// GETEX key [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | PERSIST]
+func cmdGETEX(c *Client, args []string) []byte {
+	db := c.db()
+	key := args[0]
+	var persist bool
+	var expireAt int64 = -1
+	for i := 1; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		switch {
+		case opt == "PERSIST" && expireAt < 0:
+			persist = true
+		case (opt == "EX" || opt == "PX" || opt == "EXAT" || opt == "PXAT") && !persist && expireAt < 0 && i+1 < len(args):
+			at, err := parseExpireTime(opt, args[i+1], "getex")
+			if err != nil {
+				return Encode(err, false)
+			}
+			expireAt = at
+			i++
+		default:
+			return Encode(errSyntax, false)
+		}
+	}
+	obj, err := lookupKey(db, key, data_structure.ObjTypeString)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if obj == nil {
+		return c.Encode(nil)
+	}
+	res := Encode(data_structure.StringValue(obj.Value), false)
+	switch {
+	case expireAt >= 0 && expireAt <= time.Now().UnixMilli():
+		// an absolute time in the past deletes the key once it is read
+		db.Delete(key)
+	case expireAt >= 0:
+		db.SetExpiredAt(key, uint64(expireAt))
+	case persist:
+		db.Persist(key)
+	}
+	return res
+}
+
+// SETNX key value
+func cmdSETNX(c *Client, args []string) []byte {
+	db := c.db()
+	if db.Get(args[0]) != nil {
+		return constant.RespZero
+	}
+	db.Set(args[0], db.NewObject(data_structure.NewString(args[1])))
+	return constant.RespOne
+}
+
+// setExpireGeneric implements SETEX and PSETEX, unit is the matching SET option.
+func setExpireGeneric(c *Client, args []string, unit string, cmdName string) []byte {
+	db := c.db()
+	key, value := args[0], args[2]
+	expireAt, err := parseExpireTime(unit, args[1], cmdName)
+	if err != nil {
+		return Encode(err, false)
+	}
+	db.Set(key, db.NewObject(data_structure.NewString(value)))
+	db.SetExpiredAt(key, uint64(expireAt))
+	return constant.RespOk
+}
+
+// SETEX key seconds value
+func cmdSETEX(c *Client, args []string) []byte {
+	return setExpireGeneric(c, args, "EX", "setex")
+}
+
+// PSETEX key milliseconds value
+func cmdPSETEX(c *Client, args []string) []byte {
+	return setExpireGeneric(c, args, "PX", "psetex")
+}
+
+// LCS key1 key2 [LEN] [IDX] [MINMATCHLEN min-match-len] [WITHMATCHLEN]
+func cmdLCS(c *Client, args []string) []byte {
+	db := c.db()
+	var getLen, getIdx, withMatchLen bool
+	var minMatchLen int64
+	for i := 2; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		switch {
+		case opt == "LEN":
+			getLen = true
+		case opt == "IDX":
+			getIdx = true
+		case opt == "WITHMATCHLEN":
+			withMatchLen = true
+		case opt == "MINMATCHLEN" && i+1 < len(args):
+			v, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return Encode(errNotInteger, false)
+			}
+			minMatchLen = max(v, 0)
+			i++
+		default:
+			return Encode(errSyntax, false)
+		}
+	}
+	if getLen && getIdx {
+		return Encode(errors.New("ERR If you want both the length and indexes, please just use IDX."), false)
+	}
+	var strs [2]string
+	for i, key := range args[:2] {
+		obj := db.Get(key)
+		if obj == nil {
+			continue
+		}
+		if obj.Type != data_structure.ObjTypeString {
+			return Encode(errors.New("ERR The specified keys must contain string values"), false)
+		}
+		strs[i] = data_structure.StringValue(obj.Value)
+	}
+	a, b := strs[0], strs[1]
+	alen, blen := len(a), len(b)
+	if uint64(alen+1)*uint64(blen+1)*4 > uint64(config.ProtoMaxBulkLen) {
+		return Encode(errors.New("ERR Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len"), false)
+	}
+
+	// lcs[i*(blen+1)+j] is the length of the LCS of a[:i] and b[:j]
+	lcs := make([]uint32, (alen+1)*(blen+1))
+	at := func(i, j int) uint32 { return lcs[i*(blen+1)+j] }
+	for i := 1; i <= alen; i++ {
+		for j := 1; j <= blen; j++ {
+			if a[i-1] == b[j-1] {
+				lcs[i*(blen+1)+j] = at(i-1, j-1) + 1
+			} else {
+				lcs[i*(blen+1)+j] = max(at(i-1, j), at(i, j-1))
+			}
+		}
+	}
+	n := int(at(alen, blen))
+	if getLen {
+		return Encode(n, false)
+	}
+
+	// walk back from the end, collecting the common bytes and, for IDX, the ranges
+	// of contiguous matches, last ones first like Redis
+	res := make([]byte, n)
+	matches := []interface{}{}
+	idx := n
+	i, j := alen, blen
+	astart, aend, bstart, bend := alen, 0, 0, 0
+	for i > 0 && j > 0 {
+		emit := false
+		if a[i-1] == b[j-1] {
+			res[idx-1] = a[i-1]
+			if astart == alen {
+				astart, aend, bstart, bend = i-1, i-1, j-1, j-1
+			} else if astart == i && bstart == j {
+				// the match extends the current range backward
+				astart--
+				bstart--
+			} else {
+				emit = true
+			}
+			if astart == 0 || bstart == 0 {
+				emit = true
+			}
+			idx--
+			i--
+			j--
+		} else {
+			if at(i-1, j) > at(i, j-1) {
+				i--
+			} else {
+				j--
+			}
+			if astart != alen {
+				emit = true
+			}
+		}
+		if emit {
+			matchLen := aend - astart + 1
+			if getIdx && (minMatchLen == 0 || int64(matchLen) >= minMatchLen) {
+				match := []interface{}{
+					[]interface{}{astart, aend},
+					[]interface{}{bstart, bend},
+				}
+				if withMatchLen {
+					match = append(match, matchLen)
+				}
+				matches = append(matches, match)
+			}
+			astart = alen
+		}
+	}
+	if getIdx {
+		return c.Encode(RespMap{"matches", matches, "len", n})
+	}
+	return Encode(string(res), false)
+}
+
+// MGET key [key ...]
+func cmdMGET(c *Client, args []string) []byte {
+	db := c.db()
+	res := make([]interface{}, len(args))
+	for i, key := range args {
+		obj := db.Get(key)
+		if obj == nil || obj.Type != data_structure.ObjTypeString {
+			// MGET never fails, keys of other types read as missing
+			res[i] = RespNull{}
+			continue
+		}
+		res[i] = data_structure.StringValue(obj.Value)
+	}
+	return c.Encode(res)
+}
+
+// msetGeneric stores every key value pair of args, MSETNX only does when none of the
+// keys exists. Eviction already ran once for the whole batch before the command.
+func msetGeneric(c *Client, args []string, nx bool) bool {
+	db := c.db()
+	if nx {
+		for i := 0; i < len(args); i += 2 {
+			if db.Get(args[i]) != nil {
+				return false
+			}
+		}
+	}
+	for i := 0; i < len(args); i += 2 {
+		db.Set(args[i], db.NewObject(data_structure.NewString(args[i+1])))
+	}
+	return true
+}
+
+// MSET key value [key value ...]
+func cmdMSET(c *Client, args []string) []byte {
+	if len(args)%2 != 0 {
+		return Encode(errWrongArgs("mset"), false)
+	}
+	msetGeneric(c, args, false)
+	return constant.RespOk
+}
+
+// MSETNX key value [key value ...]
+func cmdMSETNX(c *Client, args []string) []byte {
+	if len(args)%2 != 0 {
+		return Encode(errWrongArgs("msetnx"), false)
+	}
+	if !msetGeneric(c, args, true) {
+		return constant.RespZero
+	}
+	return constant.RespOne
+}
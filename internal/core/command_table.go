@@ -0,0 +1,367 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type CommandFlag uint32
+
+const (
+	// CmdWrite commands may modify the keyspace
+	CmdWrite CommandFlag = 1 << iota
+	// CmdReadonly commands only read from keys
+	CmdReadonly
+	// CmdDenyOOM commands may grow memory usage and are refused when over maxmemory
+	CmdDenyOOM
+	// CmdAdmin commands are administrative, e.g. flushing or reconfiguring the server
+	CmdAdmin
+	// CmdPubSub commands belong to publish/subscribe
+	CmdPubSub
+	// CmdNoScript commands are not allowed from scripts
+	CmdNoScript
+	// CmdFast commands run in O(1) or O(log N) and never block
+	CmdFast
+)
+
+var commandFlagNames = []struct {
+	flag CommandFlag
+	name string
+}{
+	{CmdWrite, "write"},
+	{CmdReadonly, "readonly"},
+	{CmdDenyOOM, "denyoom"},
+	{CmdAdmin, "admin"},
+	{CmdPubSub, "pubsub"},
+	{CmdNoScript, "noscript"},
+	{CmdFast, "fast"},
+}
+
+type CommandHandler func(c *Client, args []string) []byte
+
+// RedisCommand describes a command the way the command table in Redis does.
+type RedisCommand struct {
+	// Name is the lower case command name
+	Name    string
+	Handler CommandHandler
+	// Arity counts the command name itself, a negative value -N means at least N
+	Arity int
+	Flags CommandFlag
+	// FirstKey, LastKey and Step give the positions of the key arguments, counting the
+	// command name as 0. LastKey -1 means the last argument, and all of them are 0 for
+	// commands without keys.
+	FirstKey int
+	LastKey  int
+	Step     int
+	// Group, Summary and Since are reported by COMMAND DOCS
+	Group   string
+	Summary string
+	Since   string
+}
+
+func (rc *RedisCommand) Has(flag CommandFlag) bool {
+	return rc.Flags&flag != 0
+}
+
+// KeyIndexes returns the positions in args (which excludes the command name) of the keys
+// the command operates on.
+func (rc *RedisCommand) KeyIndexes(args []string) []int {
+	if rc.FirstKey == 0 {
+		return nil
+	}
+	last := rc.LastKey
+	if last < 0 {
+		last = len(args) + 1 + last
+	}
+	var res []int
+	for i := rc.FirstKey; i <= last && i <= len(args); i += rc.Step {
+		res = append(res, i-1)
+	}
+	return res
+}
+
+// commandTable maps the upper case command name to its entry
+var commandTable map[string]*RedisCommand
+
+func registerCommands(cmds ...*RedisCommand) {
+	for _, rc := range cmds {
+		commandTable[strings.ToUpper(rc.Name)] = rc
+	}
+}
+
+// lookupCommand returns the entry for a command name in any case, or nil.
+func lookupCommand(name string) *RedisCommand {
+	return commandTable[strings.ToUpper(name)]
+}
+
+func init() {
+	commandTable = make(map[string]*RedisCommand)
+	registerCommands(
+		// connection
+		&RedisCommand{Name: "ping", Handler: cmdPING, Arity: -1, Flags: CmdFast, Group: "connection", Summary: "Returns the server's liveliness response.", Since: "1.0.0"},
+		&RedisCommand{Name: "hello", Handler: cmdHELLO, Arity: -1, Flags: CmdNoScript | CmdFast, Group: "connection", Summary: "Handshakes with the Redis server.", Since: "6.0.0"},
+		&RedisCommand{Name: "select", Handler: cmdSELECT, Arity: 2, Flags: CmdFast, Group: "connection", Summary: "Changes the selected database.", Since: "1.0.0"},
+		// server
+		&RedisCommand{Name: "info", Handler: cmdInfo, Arity: -1, Group: "server", Summary: "Returns information and statistics about the server.", Since: "1.0.0"},
+		&RedisCommand{Name: "dbsize", Handler: cmdDBSIZE, Arity: 1, Flags: CmdReadonly | CmdFast, Group: "server", Summary: "Returns the number of keys in the database.", Since: "1.0.0"},
+		&RedisCommand{Name: "flushdb", Handler: cmdFLUSHDB, Arity: -1, Flags: CmdWrite, Group: "server", Summary: "Removes all keys from the current database.", Since: "1.0.0"},
+		&RedisCommand{Name: "flushall", Handler: cmdFLUSHALL, Arity: -1, Flags: CmdWrite, Group: "server", Summary: "Removes all keys from all databases.", Since: "1.0.0"},
+		&RedisCommand{Name: "swapdb", Handler: cmdSWAPDB, Arity: 3, Flags: CmdWrite | CmdFast, Group: "server", Summary: "Swaps two Redis databases.", Since: "4.0.0"},
+		&RedisCommand{Name: "memory", Handler: cmdMEMORY, Arity: -2, Flags: CmdReadonly, Group: "server", Summary: "Reports on memory usage of a key or of the server.", Since: "4.0.0"},
+		&RedisCommand{Name: "command", Handler: cmdCOMMAND, Arity: -1, Group: "server", Summary: "Returns detailed information about all commands.", Since: "2.8.13"},
+		// string
+		&RedisCommand{Name: "set", Handler: cmdSet, Arity: -3, Flags: CmdWrite | CmdDenyOOM, FirstKey: 1, LastKey: 1, Step: 1, Group: "string", Summary: "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist.", Since: "1.0.0"},
+		&RedisCommand{Name: "get", Handler: cmdGet, Arity: 2, Flags: CmdReadonly | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "string", Summary: "Returns the string value of a key.", Since: "1.0.0"},
+		&RedisCommand{Name: "getset", Handler: cmdGETSET, Arity: 3, Flags: CmdWrite | CmdDenyOOM | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "string", Summary: "Returns the previous string value of a key after setting it to a new value.", Since: "1.0.0"},
+		&RedisCommand{Name: "getdel", Handler: cmdGETDEL, Arity: 2, Flags: CmdWrite | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "string", Summary: "Returns the string value of a key after deleting the key.", Since: "6.2.0"},
+		&RedisCommand{Name: "getex", Handler: cmdGETEX, Arity: -2, Flags: CmdWrite | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "string", Summary: "Returns the string value of a key after setting its expiration time.", Since: "6.2.0"},
+		&RedisCommand{Name: "setnx", Handler: cmdSETNX, Arity: 3, Flags: CmdWrite | CmdDenyOOM | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "string", Summary: "Set the string value of a key only when the key doesn't exist.", Since: "1.0.0"},
+		&RedisCommand{Name: "setex", Handler: cmdSETEX, Arity: 4, Flags: CmdWrite | CmdDenyOOM, FirstKey: 1, LastKey: 1, Step: 1, Group: "string", Summary: "Sets the string value and expiration time of a key. Creates the key if it doesn't exist.", Since: "2.0.0"},
+		&RedisCommand{Name: "psetex", Handler: cmdPSETEX, Arity: 4, Flags: CmdWrite | CmdDenyOOM, FirstKey: 1, LastKey: 1, Step: 1, Group: "string", Summary: "Sets both string value and expiration time in milliseconds of a key. The key is created if it doesn't exist.", Since: "2.6.0"},
+		&RedisCommand{Name: "append", Handler: cmdAPPEND, Arity: 3, Flags: CmdWrite | CmdDenyOOM | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "string", Summary: "Appends a string to the value of a key. Creates the key if it doesn't exist.", Since: "2.0.0"},
+		&RedisCommand{Name: "strlen", Handler: cmdSTRLEN, Arity: 2, Flags: CmdReadonly | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "string", Summary: "Returns the length of a string value.", Since: "2.2.0"},
+		&RedisCommand{Name: "getrange", Handler: cmdGETRANGE, Arity: 4, Flags: CmdReadonly, FirstKey: 1, LastKey: 1, Step: 1, Group: "string", Summary: "Returns a substring of the string stored at a key.", Since: "2.4.0"},
+		&RedisCommand{Name: "setrange", Handler: cmdSETRANGE, Arity: 4, Flags: CmdWrite | CmdDenyOOM, FirstKey: 1, LastKey: 1, Step: 1, Group: "string", Summary: "Overwrites a part of a string value with another by an offset. Creates the key if it doesn't exist.", Since: "2.2.0"},
+		&RedisCommand{Name: "lcs", Handler: cmdLCS, Arity: -3, Flags: CmdReadonly, FirstKey: 1, LastKey: 2, Step: 1, Group: "string", Summary: "Finds the longest common substring.", Since: "7.0.0"},
+		&RedisCommand{Name: "mget", Handler: cmdMGET, Arity: -2, Flags: CmdReadonly | CmdFast, FirstKey: 1, LastKey: -1, Step: 1, Group: "string", Summary: "Atomically returns the string values of one or more keys.", Since: "1.0.0"},
+		&RedisCommand{Name: "mset", Handler: cmdMSET, Arity: -3, Flags: CmdWrite | CmdDenyOOM, FirstKey: 1, LastKey: -1, Step: 2, Group: "string", Summary: "Atomically creates or modifies the string values of one or more keys.", Since: "1.0.1"},
+		&RedisCommand{Name: "msetnx", Handler: cmdMSETNX, Arity: -3, Flags: CmdWrite | CmdDenyOOM, FirstKey: 1, LastKey: -1, Step: 2, Group: "string", Summary: "Atomically modifies the string values of one or more keys only when all keys don't exist.", Since: "1.0.1"},
+		&RedisCommand{Name: "incr", Handler: cmdINCR, Arity: 2, Flags: CmdWrite | CmdDenyOOM | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "string", Summary: "Increments the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.", Since: "1.0.0"},
+		&RedisCommand{Name: "decr", Handler: cmdDECR, Arity: 2, Flags: CmdWrite | CmdDenyOOM | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "string", Summary: "Decrements the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.", Since: "1.0.0"},
+		&RedisCommand{Name: "incrby", Handler: cmdINCRBY, Arity: 3, Flags: CmdWrite | CmdDenyOOM | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "string", Summary: "Increments the integer value of a key by a number. Uses 0 as initial value if the key doesn't exist.", Since: "1.0.0"},
+		&RedisCommand{Name: "decrby", Handler: cmdDECRBY, Arity: 3, Flags: CmdWrite | CmdDenyOOM | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "string", Summary: "Decrements a number from the integer value of a key. Uses 0 as initial value if the key doesn't exist.", Since: "1.0.0"},
+		&RedisCommand{Name: "incrbyfloat", Handler: cmdINCRBYFLOAT, Arity: 3, Flags: CmdWrite | CmdDenyOOM | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "string", Summary: "Increment the floating point value of a key by a number. Uses 0 as initial value if the key doesn't exist.", Since: "2.6.0"},
+		// bitmap
+		&RedisCommand{Name: "setbit", Handler: cmdSETBIT, Arity: 4, Flags: CmdWrite | CmdDenyOOM, FirstKey: 1, LastKey: 1, Step: 1, Group: "bitmap", Summary: "Sets or clears the bit at offset of the string value. Creates the key if it doesn't exist.", Since: "2.2.0"},
+		&RedisCommand{Name: "getbit", Handler: cmdGETBIT, Arity: 3, Flags: CmdReadonly | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "bitmap", Summary: "Returns a bit value by offset.", Since: "2.2.0"},
+		&RedisCommand{Name: "bitcount", Handler: cmdBITCOUNT, Arity: -2, Flags: CmdReadonly, FirstKey: 1, LastKey: 1, Step: 1, Group: "bitmap", Summary: "Counts the number of set bits (population counting) in a string.", Since: "2.6.0"},
+		&RedisCommand{Name: "bitpos", Handler: cmdBITPOS, Arity: -3, Flags: CmdReadonly, FirstKey: 1, LastKey: 1, Step: 1, Group: "bitmap", Summary: "Finds the first set (1) or clear (0) bit in a string.", Since: "2.8.7"},
+		&RedisCommand{Name: "bitop", Handler: cmdBITOP, Arity: -4, Flags: CmdWrite | CmdDenyOOM, FirstKey: 2, LastKey: -1, Step: 1, Group: "bitmap", Summary: "Performs bitwise operations on multiple strings, and stores the result.", Since: "2.6.0"},
+		&RedisCommand{Name: "bitfield", Handler: cmdBITFIELD, Arity: -2, Flags: CmdWrite | CmdDenyOOM, FirstKey: 1, LastKey: 1, Step: 1, Group: "bitmap", Summary: "Performs arbitrary bitfield integer operations on strings.", Since: "3.2.0"},
+		// generic
+		&RedisCommand{Name: "ttl", Handler: cmdTTL, Arity: 2, Flags: CmdReadonly | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "generic", Summary: "Returns the expiration time in seconds of a key.", Since: "1.0.0"},
+		&RedisCommand{Name: "pttl", Handler: cmdPTTL, Arity: 2, Flags: CmdReadonly | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "generic", Summary: "Returns the expiration time in milliseconds of a key.", Since: "2.6.0"},
+		&RedisCommand{Name: "expiretime", Handler: cmdEXPIRETIME, Arity: 2, Flags: CmdReadonly | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "generic", Summary: "Returns the expiration time of a key as a Unix timestamp.", Since: "7.0.0"},
+		&RedisCommand{Name: "pexpiretime", Handler: cmdPEXPIRETIME, Arity: 2, Flags: CmdReadonly | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "generic", Summary: "Returns the expiration time of a key as a Unix milliseconds timestamp.", Since: "7.0.0"},
+		&RedisCommand{Name: "persist", Handler: cmdPERSIST, Arity: 2, Flags: CmdWrite | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "generic", Summary: "Removes the expiration time of a key.", Since: "2.2.0"},
+		&RedisCommand{Name: "pexpire", Handler: cmdPEXPIRE, Arity: -3, Flags: CmdWrite | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "generic", Summary: "Sets the expiration time of a key in milliseconds.", Since: "2.6.0"},
+		&RedisCommand{Name: "expireat", Handler: cmdEXPIREAT, Arity: -3, Flags: CmdWrite | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "generic", Summary: "Sets the expiration time of a key to a Unix timestamp.", Since: "1.2.0"},
+		&RedisCommand{Name: "pexpireat", Handler: cmdPEXPIREAT, Arity: -3, Flags: CmdWrite | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "generic", Summary: "Sets the expiration time of a key to a Unix milliseconds timestamp.", Since: "2.6.0"},
+		&RedisCommand{Name: "expire", Handler: cmdExpire, Arity: -3, Flags: CmdWrite | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "generic", Summary: "Sets the expiration time of a key in seconds.", Since: "1.0.0"},
+		&RedisCommand{Name: "del", Handler: cmdDel, Arity: -2, Flags: CmdWrite, FirstKey: 1, LastKey: -1, Step: 1, Group: "generic", Summary: "Deletes one or more keys.", Since: "1.0.0"},
+		&RedisCommand{Name: "unlink", Handler: cmdUNLINK, Arity: -2, Flags: CmdWrite | CmdFast, FirstKey: 1, LastKey: -1, Step: 1, Group: "generic", Summary: "Asynchronously deletes one or more keys.", Since: "4.0.0"},
+		&RedisCommand{Name: "dump", Handler: cmdDUMP, Arity: 2, Flags: CmdReadonly, FirstKey: 1, LastKey: 1, Step: 1, Group: "generic", Summary: "Returns a serialized representation of the value stored at a key.", Since: "2.6.0"},
+		&RedisCommand{Name: "restore", Handler: cmdRESTORE, Arity: -4, Flags: CmdWrite | CmdDenyOOM, FirstKey: 1, LastKey: 1, Step: 1, Group: "generic", Summary: "Creates a key from the serialized representation of a value.", Since: "2.6.0"},
+		&RedisCommand{Name: "type", Handler: cmdTYPE, Arity: 2, Flags: CmdReadonly | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "generic", Summary: "Determines the type of value stored at a key.", Since: "1.0.0"},
+		&RedisCommand{Name: "rename", Handler: cmdRENAME, Arity: 3, Flags: CmdWrite, FirstKey: 1, LastKey: 2, Step: 1, Group: "generic", Summary: "Renames a key and overwrites the destination.", Since: "1.0.0"},
+		&RedisCommand{Name: "renamenx", Handler: cmdRENAMENX, Arity: 3, Flags: CmdWrite | CmdFast, FirstKey: 1, LastKey: 2, Step: 1, Group: "generic", Summary: "Renames a key only when the target key name doesn't exist.", Since: "1.0.0"},
+		&RedisCommand{Name: "move", Handler: cmdMOVE, Arity: 3, Flags: CmdWrite | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "generic", Summary: "Moves a key to another database.", Since: "1.0.0"},
+		&RedisCommand{Name: "copy", Handler: cmdCOPY, Arity: -3, Flags: CmdWrite | CmdDenyOOM, FirstKey: 1, LastKey: 2, Step: 1, Group: "generic", Summary: "Copies the value of a key to a new key.", Since: "6.2.0"},
+		&RedisCommand{Name: "randomkey", Handler: cmdRANDOMKEY, Arity: 1, Flags: CmdReadonly, Group: "generic", Summary: "Returns a random key name from the database.", Since: "1.0.0"},
+		&RedisCommand{Name: "touch", Handler: cmdTOUCH, Arity: -2, Flags: CmdReadonly | CmdFast, FirstKey: 1, LastKey: -1, Step: 1, Group: "generic", Summary: "Returns the number of existing keys out of those specified after updating the time they were last accessed.", Since: "3.2.1"},
+		&RedisCommand{Name: "keys", Handler: cmdKEYS, Arity: 2, Flags: CmdReadonly, Group: "generic", Summary: "Returns all key names that match a pattern.", Since: "1.0.0"},
+		&RedisCommand{Name: "scan", Handler: cmdSCAN, Arity: -2, Flags: CmdReadonly, Group: "generic", Summary: "Iterates over the key names in the database.", Since: "2.8.0"},
+		&RedisCommand{Name: "object", Handler: cmdOBJECT, Arity: -2, Flags: CmdReadonly, FirstKey: 2, LastKey: 2, Step: 1, Group: "generic", Summary: "A container for object introspection commands.", Since: "2.2.3"},
+		&RedisCommand{Name: "exists", Handler: cmdExists, Arity: -2, Flags: CmdReadonly | CmdFast, FirstKey: 1, LastKey: -1, Step: 1, Group: "generic", Summary: "Determines whether one or more keys exist.", Since: "1.0.0"},
+		// list
+		&RedisCommand{Name: "lpush", Handler: cmdLPUSH, Arity: -3, Flags: CmdWrite | CmdDenyOOM | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "list", Summary: "Prepends one or more elements to a list. Creates the key if it doesn't exist.", Since: "1.0.0"},
+		&RedisCommand{Name: "rpush", Handler: cmdRPUSH, Arity: -3, Flags: CmdWrite | CmdDenyOOM | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "list", Summary: "Appends one or more elements to a list. Creates the key if it doesn't exist.", Since: "1.0.0"},
+		&RedisCommand{Name: "lpushx", Handler: cmdLPUSHX, Arity: -3, Flags: CmdWrite | CmdDenyOOM | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "list", Summary: "Prepends one or more elements to a list only when the list exists.", Since: "2.2.0"},
+		&RedisCommand{Name: "rpushx", Handler: cmdRPUSHX, Arity: -3, Flags: CmdWrite | CmdDenyOOM | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "list", Summary: "Appends an element to a list only when the list exists.", Since: "2.2.0"},
+		&RedisCommand{Name: "lpop", Handler: cmdLPOP, Arity: -2, Flags: CmdWrite | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "list", Summary: "Returns the first elements in a list after removing it. Deletes the list if the last element was popped.", Since: "1.0.0"},
+		&RedisCommand{Name: "rpop", Handler: cmdRPOP, Arity: -2, Flags: CmdWrite | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "list", Summary: "Returns and removes the last elements of a list. Deletes the list if the last element was popped.", Since: "1.0.0"},
+		&RedisCommand{Name: "llen", Handler: cmdLLEN, Arity: 2, Flags: CmdReadonly | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "list", Summary: "Returns the length of a list.", Since: "1.0.0"},
+		&RedisCommand{Name: "lrange", Handler: cmdLRANGE, Arity: 4, Flags: CmdReadonly, FirstKey: 1, LastKey: 1, Step: 1, Group: "list", Summary: "Returns a range of elements from a list.", Since: "1.0.0"},
+		&RedisCommand{Name: "lindex", Handler: cmdLINDEX, Arity: 3, Flags: CmdReadonly, FirstKey: 1, LastKey: 1, Step: 1, Group: "list", Summary: "Returns an element from a list by its index.", Since: "1.0.0"},
+		&RedisCommand{Name: "lset", Handler: cmdLSET, Arity: 4, Flags: CmdWrite | CmdDenyOOM, FirstKey: 1, LastKey: 1, Step: 1, Group: "list", Summary: "Sets the value of an element in a list by its index.", Since: "1.0.0"},
+		&RedisCommand{Name: "linsert", Handler: cmdLINSERT, Arity: 5, Flags: CmdWrite | CmdDenyOOM, FirstKey: 1, LastKey: 1, Step: 1, Group: "list", Summary: "Inserts an element before or after another element in a list.", Since: "2.2.0"},
+		&RedisCommand{Name: "lrem", Handler: cmdLREM, Arity: 4, Flags: CmdWrite, FirstKey: 1, LastKey: 1, Step: 1, Group: "list", Summary: "Removes elements from a list. Deletes the list if the last element was removed.", Since: "1.0.0"},
+		&RedisCommand{Name: "ltrim", Handler: cmdLTRIM, Arity: 4, Flags: CmdWrite, FirstKey: 1, LastKey: 1, Step: 1, Group: "list", Summary: "Removes elements from both ends a list. Deletes the list if all elements were trimmed.", Since: "1.0.0"},
+		&RedisCommand{Name: "lpos", Handler: cmdLPOS, Arity: -3, Flags: CmdReadonly, FirstKey: 1, LastKey: 1, Step: 1, Group: "list", Summary: "Returns the index of matching elements in a list.", Since: "6.0.6"},
+		&RedisCommand{Name: "lmove", Handler: cmdLMOVE, Arity: 5, Flags: CmdWrite | CmdDenyOOM, FirstKey: 1, LastKey: 2, Step: 1, Group: "list", Summary: "Returns an element after popping it from one list and pushing it to another. Deletes the list if the last element was moved.", Since: "6.2.0"},
+		&RedisCommand{Name: "rpoplpush", Handler: cmdRPOPLPUSH, Arity: 3, Flags: CmdWrite | CmdDenyOOM, FirstKey: 1, LastKey: 2, Step: 1, Group: "list", Summary: "Returns the last element of a list after removing and pushing it to another list. Deletes the list if the last element was popped.", Since: "1.2.0"},
+		// sorted set
+		&RedisCommand{Name: "zadd", Handler: cmdZADD, Arity: -4, Flags: CmdWrite | CmdDenyOOM | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "sorted-set", Summary: "Adds one or more members to a sorted set, or updates their scores.", Since: "1.2.0"},
+		&RedisCommand{Name: "zscore", Handler: cmdZSCORE, Arity: 3, Flags: CmdReadonly | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "sorted-set", Summary: "Returns the score of a member in a sorted set.", Since: "1.2.0"},
+		&RedisCommand{Name: "zrank", Handler: cmdZRANK, Arity: 3, Flags: CmdReadonly | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "sorted-set", Summary: "Returns the index of a member in a sorted set ordered by ascending scores.", Since: "2.0.0"},
+		&RedisCommand{Name: "zscan", Handler: cmdZSCAN, Arity: -3, Flags: CmdReadonly, FirstKey: 1, LastKey: 1, Step: 1, Group: "sorted-set", Summary: "Iterates over members and scores of a sorted set.", Since: "2.8.0"},
+		// set
+		&RedisCommand{Name: "sadd", Handler: cmdSADD, Arity: -3, Flags: CmdWrite | CmdDenyOOM | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "set", Summary: "Adds one or more members to a set. Creates the key if it doesn't exist.", Since: "1.0.0"},
+		&RedisCommand{Name: "srem", Handler: cmdSREM, Arity: -3, Flags: CmdWrite | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "set", Summary: "Removes one or more members from a set.", Since: "1.0.0"},
+		&RedisCommand{Name: "smembers", Handler: cmdSMEMBERS, Arity: 2, Flags: CmdReadonly, FirstKey: 1, LastKey: 1, Step: 1, Group: "set", Summary: "Returns all members of a set.", Since: "1.0.0"},
+		&RedisCommand{Name: "sismember", Handler: cmdSISMEMBER, Arity: 3, Flags: CmdReadonly | CmdFast, FirstKey: 1, LastKey: 1, Step: 1, Group: "set", Summary: "Determines whether a member belongs to a set.", Since: "1.0.0"},
+		&RedisCommand{Name: "sscan", Handler: cmdSSCAN, Arity: -3, Flags: CmdReadonly, FirstKey: 1, LastKey: 1, Step: 1, Group: "set", Summary: "Iterates over members of a set.", Since: "2.8.0"},
+		// count-min sketch
+		&RedisCommand{Name: "cms.initbydim", Handler: cmdCMSINITBYDIM, Arity: 4, Flags: CmdWrite | CmdDenyOOM, FirstKey: 1, LastKey: 1, Step: 1, Group: "cms", Summary: "Initializes a Count-Min Sketch to dimensions specified by user.", Since: "2.0.0"},
+		&RedisCommand{Name: "cms.initbyprob", Handler: cmdCMSINITBYPROB, Arity: 4, Flags: CmdWrite | CmdDenyOOM, FirstKey: 1, LastKey: 1, Step: 1, Group: "cms", Summary: "Initializes a Count-Min Sketch to accommodate requested tolerances.", Since: "2.0.0"},
+		&RedisCommand{Name: "cms.incrby", Handler: cmdCMSINCRBY, Arity: -4, Flags: CmdWrite | CmdDenyOOM, FirstKey: 1, LastKey: 1, Step: 1, Group: "cms", Summary: "Increases the count of one or more items by increment.", Since: "2.0.0"},
+		&RedisCommand{Name: "cms.query", Handler: cmdCMSQUERY, Arity: -3, Flags: CmdReadonly, FirstKey: 1, LastKey: 1, Step: 1, Group: "cms", Summary: "Returns the count for one or more items in a sketch.", Since: "2.0.0"},
+		// bloom filter
+		&RedisCommand{Name: "bf.reserve", Handler: cmdBFRESERVE, Arity: -4, Flags: CmdWrite | CmdDenyOOM, FirstKey: 1, LastKey: 1, Step: 1, Group: "bf", Summary: "Creates a new Bloom Filter.", Since: "1.0.0"},
+		&RedisCommand{Name: "bf.madd", Handler: cmdBFMADD, Arity: -3, Flags: CmdWrite | CmdDenyOOM, FirstKey: 1, LastKey: 1, Step: 1, Group: "bf", Summary: "Adds one or more items to a Bloom Filter. A filter will be created if it does not exist.", Since: "1.0.0"},
+		&RedisCommand{Name: "bf.exists", Handler: cmdBFEXISTS, Arity: 3, Flags: CmdReadonly, FirstKey: 1, LastKey: 1, Step: 1, Group: "bf", Summary: "Checks whether an item exists in a Bloom Filter.", Since: "1.0.0"},
+	)
+}
+
+// errWrongArgs is the error Redis returns for an argument count the command does not accept.
+func errWrongArgs(name string) error {
+	return fmt.Errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(name))
+}
+
+// errUnknownCommand mirrors the Redis error, quoting the first arguments to help spot typos.
+func errUnknownCommand(cmd *Command) error {
+	var sb strings.Builder
+	for _, arg := range cmd.Args {
+		if sb.Len() >= 128 {
+			break
+		}
+		if len(arg) > 128-sb.Len() {
+			arg = arg[:128-sb.Len()]
+		}
+		sb.WriteString(fmt.Sprintf("'%s' ", arg))
+	}
+	return fmt.Errorf("ERR unknown command '%s', with args beginning with: %s", strings.ToLower(cmd.Cmd), sb.String())
+}
+
+func (rc *RedisCommand) arityOk(args []string) bool {
+	if rc.Arity > 0 {
+		return len(args)+1 == rc.Arity
+	}
+	return len(args)+1 >= -rc.Arity
+}
+
+func (rc *RedisCommand) flagNames() RespSet {
+	res := RespSet{}
+	for _, f := range commandFlagNames {
+		if rc.Has(f.flag) {
+			res = append(res, RespSimpleString(f.name))
+		}
+	}
+	return res
+}
+
+// aclCategories derives the ACL categories Redis would list for the command.
+func (rc *RedisCommand) aclCategories() RespSet {
+	res := RespSet{}
+	if rc.Group != "" {
+		res = append(res, RespSimpleString("@"+strings.ReplaceAll(rc.Group, "-", "")))
+	}
+	switch {
+	case rc.Has(CmdWrite):
+		res = append(res, RespSimpleString("@write"))
+	case rc.Has(CmdReadonly):
+		res = append(res, RespSimpleString("@read"))
+	}
+	if rc.Has(CmdAdmin) {
+		res = append(res, RespSimpleString("@admin"), RespSimpleString("@dangerous"))
+	}
+	if rc.Has(CmdPubSub) {
+		res = append(res, RespSimpleString("@pubsub"))
+	}
+	if rc.Has(CmdFast) {
+		res = append(res, RespSimpleString("@fast"))
+	} else {
+		res = append(res, RespSimpleString("@slow"))
+	}
+	return res
+}
+
+func (rc *RedisCommand) info() []interface{} {
+	return []interface{}{
+		rc.Name,
+		rc.Arity,
+		rc.flagNames(),
+		rc.FirstKey,
+		rc.LastKey,
+		rc.Step,
+		rc.aclCategories(),
+		[]interface{}{}, // tips
+		[]interface{}{}, // key specs
+		[]interface{}{}, // subcommands
+	}
+}
+
+func (rc *RedisCommand) docs() RespMap {
+	return RespMap{
+		"summary", rc.Summary,
+		"since", rc.Since,
+		"group", rc.Group,
+	}
+}
+
+// sortedCommands returns the table entries ordered by name so replies are stable.
+func sortedCommands() []*RedisCommand {
+	res := make([]*RedisCommand, 0, len(commandTable))
+	for _, rc := range commandTable {
+		res = append(res, rc)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// COMMAND [COUNT | INFO [command ...] | DOCS [command ...]]
+func cmdCOMMAND(c *Client, args []string) []byte {
+	if len(args) == 0 {
+		var res []interface{}
+		for _, rc := range sortedCommands() {
+			res = append(res, rc.info())
+		}
+		return c.Encode(res)
+	}
+	switch strings.ToUpper(args[0]) {
+	case "HELP":
+		return Encode([]string{
+			"COMMAND <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+			"(no subcommand)",
+			"    Return details about all Redis commands.",
+			"COUNT",
+			"    Return the total number of commands in this Redis server.",
+			"INFO [<command-name> ...]",
+			"    Return details about multiple Redis commands.",
+			"    If no command names are given, documentation details for all",
+			"    commands are returned.",
+			"DOCS [<command-name> ...]",
+			"    Return documentation details about multiple Redis commands.",
+			"    If no command names are given, documentation details for all",
+			"    commands are returned.",
+		}, false)
+	case "COUNT":
+		if len(args) != 1 {
+			return Encode(fmt.Errorf("ERR unknown subcommand or wrong number of arguments for '%s'. Try COMMAND HELP.", args[0]), false)
+		}
+		return Encode(len(commandTable), false)
+	case "INFO":
+		names := args[1:]
+		if len(names) == 0 {
+			for _, rc := range sortedCommands() {
+				names = append(names, rc.Name)
+			}
+		}
+		res := make([]interface{}, len(names))
+		for i, name := range names {
+			if rc := lookupCommand(name); rc != nil {
+				res[i] = rc.info()
+			}
+		}
+		return c.Encode(res)
+	case "DOCS":
+		names := args[1:]
+		if len(names) == 0 {
+			for _, rc := range sortedCommands() {
+				names = append(names, rc.Name)
+			}
+		}
+		res := RespMap{}
+		for _, name := range names {
+			if rc := lookupCommand(name); rc != nil {
+				res = append(res, rc.Name, rc.docs())
+			}
+		}
+		return c.Encode(res)
+	default:
+		return Encode(fmt.Errorf("ERR unknown subcommand '%s'. Try COMMAND HELP.", args[0]), false)
+	}
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"redis-clone/internal/config"
+	"redis-clone/internal/data_structure"
+)
+
+var errOOM = errors.New("OOM command not allowed when used memory > 'maxmemory'.")
+
+var evictStats struct {
+	evictedKeys int64
+	// peakMemory is the highest usedMemory seen after a command
+	peakMemory int64
+}
+
+// usedMemory returns the approximate bytes held by every database.
+func usedMemory() int64 {
+	var n int64
+	for _, db := range dbs {
+		n += db.UsedMemory()
+	}
+	return n
+}
+
+// performEvictions evicts keys of every database until the used memory is back under
+// config.MaxMemory. It returns false when it could not get there.
+func performEvictions() bool {
+	if config.MaxMemory <= 0 {
+		return true
+	}
+	for usedMemory() > config.MaxMemory {
+		if !data_structure.Evict(dbs) {
+			return false
+		}
+		evictStats.evictedKeys++
+	}
+	return true
+}
+
+// updatePeakMemory records the used memory when it is the highest seen.
+func updatePeakMemory() {
+	if used := usedMemory(); used > evictStats.peakMemory {
+		evictStats.peakMemory = used
+	}
+}
+
+// bytesToHuman formats n like the *_human fields of Redis INFO.
+func bytesToHuman(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	value, suffix := float64(n)/unit, "K"
+	for _, s := range []string{"M", "G", "T", "P"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, s
+	}
+	return fmt.Sprintf("%.2f%s", value, suffix)
+}
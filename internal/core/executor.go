@@ -2,20 +2,21 @@ package core
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
+	"math"
+	"redis-clone/internal/config"
 	"redis-clone/internal/constant"
 	"redis-clone/internal/data_structure"
 	"strconv"
-	"syscall"
+	"strings"
 	"time"
 )
 
 // true doi voi string don gian va false doi voi error va string phuc tap
-func cmdPING(args []string) []byte {
+func cmdPING(c *Client, args []string) []byte {
 	var res []byte
 	if len(args) > 1 {
-		return Encode(errors.New("ERR wrong number of arguments for 'ping' command"), false)
+		return Encode(errWrongArgs("ping"), false)
 	}
 
 	if len(args) == 0 {
@@ -26,104 +27,143 @@ func cmdPING(args []string) []byte {
 	return res
 }
 
-func cmdSet(args []string) []byte {
-	if len(args) < 2 || len(args) == 3 || len(args) > 4 {
-		return Encode(errors.New("ERR wrong number of 'SET' command"), false)
+// SET key value [NX | XX] [GET] [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL]
+func cmdSet(c *Client, args []string) []byte {
+	key, value := args[0], args[1]
+	var nx, xx, get, keepTTL bool
+	var expireAt int64 = -1
+	for i := 2; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		hasNext := i+1 < len(args)
+		switch {
+		case opt == "NX" && !xx:
+			nx = true
+		case opt == "XX" && !nx:
+			xx = true
+		case opt == "GET":
+			get = true
+		case opt == "KEEPTTL" && expireAt < 0:
+			keepTTL = true
+		case (opt == "EX" || opt == "PX" || opt == "EXAT" || opt == "PXAT") && !keepTTL && expireAt < 0 && hasNext:
+			at, err := parseExpireTime(opt, args[i+1], "set")
+			if err != nil {
+				return Encode(err, false)
+			}
+			expireAt = at
+			i++
+		default:
+			return Encode(errSyntax, false)
+		}
 	}
 
-	var key, value string
-	key, value = args[0], args[1]
-	var ttlMs int64 = -1
-	if len(args) > 2 {
-		ttlSec, err := strconv.ParseInt(args[3], 10, 64)
-		if err != nil {
-			return Encode(errors.New("(error) ERR value is not an integer or out of range"), false)
+	db := c.db()
+	obj := db.Get(key)
+	var old []byte
+	if get {
+		if obj != nil && obj.Type != data_structure.ObjTypeString {
+			return Encode(errWrongType, false)
+		}
+		old = c.Encode(nil)
+		if obj != nil {
+			old = Encode(data_structure.StringValue(obj.Value), false)
 		}
-		ttlMs = ttlSec * 1000
 	}
-	dictStore.Set(key, dictStore.NewObject(key, value, ttlMs))
-	return constant.RespOk
-}
+	if (nx && obj != nil) || (xx && obj == nil) {
+		if get {
+			return old
+		}
+		return c.Encode(nil)
+	}
 
-func cmdGet(args []string) []byte {
-	if len(args) != 1 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'GET' command"), false)
+	if keepTTL {
+		db.SetKeepTTL(key, db.NewObject(data_structure.NewString(value)))
+	} else {
+		db.Set(key, db.NewObject(data_structure.NewString(value)))
 	}
-	key := args[0]
-	obj := dictStore.Get(key)
-	if obj == nil {
-		return constant.RespNil
+	if expireAt >= 0 {
+		db.SetExpiredAt(key, uint64(expireAt))
 	}
-	if dictStore.HasExpired(key) {
-		return constant.RespNil
+	if get {
+		return old
 	}
-	return Encode(obj.Value, false)
+	return constant.RespOk
 }
 
-func cmdTTL(args []string) []byte {
-	if len(args) != 1 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'TTL' command"), false)
+// parseExpireTime converts the argument of an EX, PX, EXAT or PXAT option into an
+// absolute unix time in milliseconds.
+func parseExpireTime(unit string, arg string, cmdName string) (int64, error) {
+	v, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return 0, errNotInteger
 	}
-	key := args[0]
-	obj := dictStore.Get(key)
-	if obj == nil {
-		return constant.TtlKeyNotExist
+	errInvalid := fmt.Errorf("ERR invalid expire time in '%s' command", cmdName)
+	if v <= 0 {
+		return 0, errInvalid
 	}
-	exp, exist := dictStore.GetExpired(key)
-	if !exist {
-		return constant.TtlKeyExistNoExpire
+	if unit == "EX" || unit == "EXAT" {
+		if v > math.MaxInt64/1000 {
+			return 0, errInvalid
+		}
+		v *= 1000
 	}
-	remainms := int64(exp) - time.Now().UnixMilli()
-	if remainms < 0 {
-		return constant.TtlKeyNotExist
+	if unit == "EX" || unit == "PX" {
+		now := time.Now().UnixMilli()
+		if v > math.MaxInt64-now {
+			return 0, errInvalid
+		}
+		v += now
 	}
-	return Encode(remainms/1000, false)
+	return v, nil
 }
 
-func cmdExpire(args []string) []byte {
-	if len(args) != 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'EXPIRE' command"), false)
-	}
+func cmdGet(c *Client, args []string) []byte {
+	db := c.db()
 	key := args[0]
-	ttlSec, err := strconv.ParseInt(args[1], 10, 64)
+	obj, err := lookupKey(db, key, data_structure.ObjTypeString)
 	if err != nil {
-		return Encode(errors.New("(error) ERR value is not an integer or out of range"), false)
+		return Encode(err, false)
 	}
-	obj := dictStore.Get(key)
 	if obj == nil {
-		return constant.ExpireKeyNotExist
+		return c.Encode(nil)
 	}
-	if ttlSec <= 0 {
-		dictStore.Delete(key)
-		return constant.ExpireKeySuccess
-	}
-	expireAt := time.Now().UnixMilli() + ttlSec*1000
-	dictStore.SetExpired(key, expireAt)
-	return constant.ExpireKeySuccess
+	return Encode(data_structure.StringValue(obj.Value), false)
 }
 
-func cmdDel(args []string) []byte {
-	if len(args) < 1 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'Del' command"), false)
-	}
+// delGeneric deletes the given keys and returns how many existed, lazy releases large
+// values in the background.
+func delGeneric(c *Client, keys []string, lazy bool) []byte {
+	db := c.db()
 	deleteCount := 0
-	for _, key := range args {
-		obj := dictStore.Get(key)
-		if obj != nil {
-			dictStore.Delete(key)
+	for _, key := range keys {
+		if db.ExpireIfNeeded(key) {
+			continue
+		}
+		var deleted bool
+		if lazy {
+			deleted = db.Unlink(key)
+		} else {
+			deleted = db.Delete(key)
+		}
+		if deleted {
 			deleteCount++
 		}
 	}
 	return Encode(int64(deleteCount), false)
 }
 
-func cmdExists(args []string) []byte {
-	if len(args) < 1 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'Exists' command"), false)
-	}
+func cmdDel(c *Client, args []string) []byte {
+	return delGeneric(c, args, config.LazyfreeLazyUserDel)
+}
+
+func cmdUNLINK(c *Client, args []string) []byte {
+	return delGeneric(c, args, true)
+}
+
+func cmdExists(c *Client, args []string) []byte {
+	db := c.db()
 	existCount := 0
 	for _, key := range args {
-		obj := dictStore.Get(key)
+		obj := db.Get(key)
 		if obj != nil {
 			existCount++
 		}
@@ -131,64 +171,61 @@ func cmdExists(args []string) []byte {
 	return Encode(int64(existCount), false)
 }
 
-func cmdInfo(args []string) []byte {
+func cmdInfo(c *Client, args []string) []byte {
 	var info []byte
 	buf := bytes.NewBuffer(info)
+	buf.WriteString("# Memory\r\n")
+	used := usedMemory()
+	buf.WriteString(fmt.Sprintf("used_memory:%d\r\n", used))
+	buf.WriteString(fmt.Sprintf("used_memory_human:%s\r\n", bytesToHuman(used)))
+	buf.WriteString(fmt.Sprintf("used_memory_peak:%d\r\n", max(evictStats.peakMemory, used)))
+	buf.WriteString(fmt.Sprintf("used_memory_peak_human:%s\r\n", bytesToHuman(max(evictStats.peakMemory, used))))
+	buf.WriteString(fmt.Sprintf("maxmemory:%d\r\n", config.MaxMemory))
+	buf.WriteString(fmt.Sprintf("maxmemory_human:%s\r\n", bytesToHuman(config.MaxMemory)))
+	buf.WriteString(fmt.Sprintf("maxmemory_policy:%s\r\n", config.EvictionPolicy))
+	buf.WriteString(fmt.Sprintf("lazyfree_pending_objects:%d\r\n", data_structure.LazyfreePendingObjects()))
+	buf.WriteString("\r\n")
+	buf.WriteString("# Stats\r\n")
+	buf.WriteString(fmt.Sprintf("expired_keys:%d\r\n", expiredKeys()))
+	buf.WriteString(fmt.Sprintf("evicted_keys:%d\r\n", evictStats.evictedKeys))
+	buf.WriteString(fmt.Sprintf("lazyfreed_objects:%d\r\n", data_structure.LazyfreedObjects()))
+	buf.WriteString(fmt.Sprintf("expired_time_cap_reached_count:%d\r\n", expireStats.timeCapReached))
+	buf.WriteString(fmt.Sprintf("expire_cycle_cpu_milliseconds:%d\r\n", expireStats.cycleTime.Milliseconds()))
+	buf.WriteString("\r\n")
 	buf.WriteString("# Keyspace\r\n")
-	buf.WriteString(fmt.Sprintf("db0:keys=%d,expires=0,avg_ttl=0\r\n", data_structure.HashKeySpaceStat.Key))
-	return Encode(buf.String(), false)
+	for i, db := range dbs {
+		// like Redis, empty databases are left out
+		if db.Len() == 0 {
+			continue
+		}
+		buf.WriteString(fmt.Sprintf("db%d:keys=%d,expires=%d,avg_ttl=0\r\n", i, db.Len(), db.ExpiresLen()))
+	}
+	return c.Encode(RespVerbatim{Format: "txt", Text: buf.String()})
 }
 
-func ExecuteAndResponse(cmd *Command, connFd int) error {
-	var res []byte
-	switch cmd.Cmd {
-	case "PING":
-		res = cmdPING(cmd.Args)
-	case "SET":
-		res = cmdSet(cmd.Args)
-	case "GET":
-		res = cmdGet(cmd.Args)
-	case "TTL":
-		res = cmdTTL(cmd.Args)
-	case "EXPIRE":
-		res = cmdExpire(cmd.Args)
-	case "DEL":
-		res = cmdDel(cmd.Args)
-	case "EXISTS":
-		res = cmdExists(cmd.Args)
-	case "INFO":
-		res = cmdInfo(cmd.Args)
-	case "ZADD":
-		res = cmdZADD(cmd.Args)
-	case "ZSCORE":
-		res = cmdZSCORE(cmd.Args)
-	case "ZRANK":
-		res = cmdZRANK(cmd.Args)
-	case "SADD":
-		res = cmdSADD(cmd.Args)
-	case "SREM":
-		res = cmdSREM(cmd.Args)
-	case "SMEMBERS":
-		res = cmdSMEMBERS(cmd.Args)
-	case "SISMEMBER":
-		res = cmdSISMEMBER(cmd.Args)
-	case "CMS.INITBYDIM":
-		res = cmdCMSINITBYDIM(cmd.Args)
-	case "CMS.INITBYPROB":
-		res = cmdCMSINITBYPROB(cmd.Args)
-	case "CMS.INCRBY":
-		res = cmdCMSINCRBY(cmd.Args)
-	case "CMS.QUERY":
-		res = cmdCMSQUERY(cmd.Args)
-	case "BF.RESERVE":
-		res = cmdBFRESERVE(cmd.Args)
-	case "BF.MADD":
-		res = cmdBFMADD(cmd.Args)
-	case "BF.EXISTS":
-		res = cmdBFEXISTS(cmd.Args)
-	default:
-		res = []byte("-CMD NOT FOUND\r\n")
-	}
-	_, err := syscall.Write(connFd, res)
-	return err
+func ExecuteAndResponse(cmd *Command, c *Client) {
+	rc := lookupCommand(cmd.Cmd)
+	if rc == nil {
+		c.Write(Encode(errUnknownCommand(cmd), false))
+		return
+	}
+	if !rc.arityOk(cmd.Args) {
+		c.Write(Encode(errWrongArgs(rc.Name), false))
+		return
+	}
+	// like Redis, memory is reclaimed before running any command, but only the
+	// commands that may grow it are refused when that fails
+	if !performEvictions() && rc.Has(CmdDenyOOM) {
+		c.Write(Encode(errOOM, false))
+		return
+	}
+	c.Write(rc.Handler(c, cmd.Args))
+	if rc.Has(CmdWrite) {
+		// values changed in place are accounted again
+		db := c.db()
+		for _, i := range rc.KeyIndexes(cmd.Args) {
+			db.UpdateSize(cmd.Args[i])
+		}
+		updatePeakMemory()
+	}
 }
@@ -0,0 +1,896 @@
+package core
+
+import (
+	"fmt"
+	"redis-clone/internal/config"
+	"redis-clone/internal/data_structure"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// run executes one command for c and returns the raw reply.
+func run(c *Client, args ...string) string {
+	ExecuteAndResponse(&Command{Cmd: strings.ToUpper(args[0]), Args: args[1:]}, c)
+	res := string(c.outBuf[c.sentLen:])
+	c.outBuf, c.sentLen = c.outBuf[:0], 0
+	return res
+}
+
+func TestCommandDispatch(t *testing.T) {
+	c := NewClient(-1)
+	assert.Equal(t, "+PONG\r\n", run(c, "ping"))
+	assert.Equal(t, "-ERR wrong number of arguments for 'get' command\r\n", run(c, "GET"))
+	assert.Equal(t, "-ERR wrong number of arguments for 'sadd' command\r\n", run(c, "SADD", "k"))
+	assert.Equal(t, "-ERR unknown command 'nope', with args beginning with: 'a' \r\n", run(c, "NOPE", "a"))
+	assert.Equal(t, Encode(len(commandTable), false), []byte(run(c, "COMMAND", "COUNT")))
+	assert.True(t, strings.HasPrefix(run(c, "COMMAND", "INFO", "get"), "*1\r\n*10\r\n$3\r\nget\r\n:2\r\n"))
+}
+
+func TestKeyIndexes(t *testing.T) {
+	assert.Equal(t, []int{0, 1, 2}, lookupCommand("del").KeyIndexes([]string{"a", "b", "c"}))
+	assert.Equal(t, []int{0}, lookupCommand("set").KeyIndexes([]string{"k", "v"}))
+	assert.Nil(t, lookupCommand("ping").KeyIndexes(nil))
+}
+
+func TestWrongType(t *testing.T) {
+	c := NewClient(-1)
+	wrongType := "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+	assert.Equal(t, "+OK\r\n", run(c, "SET", "wt:str", "v"))
+	assert.Equal(t, wrongType, run(c, "SADD", "wt:str", "a"))
+	assert.Equal(t, wrongType, run(c, "ZSCORE", "wt:str", "a"))
+	assert.Equal(t, wrongType, run(c, "BF.EXISTS", "wt:str", "a"))
+	assert.Equal(t, wrongType, run(c, "CMS.QUERY", "wt:str", "a"))
+
+	assert.Equal(t, ":1\r\n", run(c, "SADD", "wt:set", "a"))
+	assert.Equal(t, wrongType, run(c, "GET", "wt:set"))
+	assert.Equal(t, ":1\r\n", run(c, "EXISTS", "wt:set"))
+	assert.Equal(t, ":1\r\n", run(c, "EXPIRE", "wt:set", "100"))
+	assert.Equal(t, ":2\r\n", run(c, "DEL", "wt:set", "wt:str"))
+	assert.Equal(t, ":0\r\n", run(c, "EXISTS", "wt:set", "wt:str"))
+}
+
+func TestGenericKeyCommands(t *testing.T) {
+	c := NewClient(-1)
+	run(c, "FLUSHALL")
+	assert.Equal(t, "+OK\r\n", run(c, "SET", "a", "1", "EX", "100"))
+	assert.Equal(t, ":2\r\n", run(c, "SADD", "s", "x", "y"))
+	assert.Equal(t, "+string\r\n", run(c, "TYPE", "a"))
+	assert.Equal(t, "+set\r\n", run(c, "TYPE", "s"))
+	assert.Equal(t, "+none\r\n", run(c, "TYPE", "missing"))
+
+	assert.Equal(t, "+OK\r\n", run(c, "RENAME", "a", "b"))
+	assert.Contains(t, []string{":99\r\n", ":100\r\n"}, run(c, "TTL", "b"))
+	assert.Equal(t, "-ERR no such key\r\n", run(c, "RENAME", "a", "b"))
+	assert.Equal(t, ":0\r\n", run(c, "RENAMENX", "b", "s"))
+
+	assert.Equal(t, ":1\r\n", run(c, "COPY", "s", "s2"))
+	assert.Equal(t, ":0\r\n", run(c, "COPY", "s", "s2"))
+	run(c, "SREM", "s", "x")
+	assert.Equal(t, ":1\r\n", run(c, "SISMEMBER", "s2", "x"))
+	assert.Equal(t, ":1\r\n", run(c, "COPY", "b", "s2", "REPLACE"))
+	assert.Equal(t, "+string\r\n", run(c, "TYPE", "s2"))
+
+	assert.Equal(t, ":3\r\n", run(c, "DBSIZE"))
+	assert.Equal(t, ":2\r\n", run(c, "TOUCH", "b", "s", "missing"))
+	assert.Contains(t, []string{"$1\r\nb\r\n", "$1\r\ns\r\n", "$2\r\ns2\r\n"}, run(c, "RANDOMKEY"))
+	assert.Equal(t, "+OK\r\n", run(c, "FLUSHDB"))
+	assert.Equal(t, ":0\r\n", run(c, "DBSIZE"))
+	assert.Equal(t, "$-1\r\n", run(c, "RANDOMKEY"))
+}
+
+func TestKeysAndScan(t *testing.T) {
+	c := NewClient(-1)
+	run(c, "FLUSHALL")
+	for i := 0; i < 100; i++ {
+		run(c, "SET", fmt.Sprintf("user:%d", i), "v")
+	}
+	run(c, "SADD", "user:set", "a", "b", "c")
+	run(c, "ZADD", "zs", "1", "a", "2.5", "b")
+
+	keys, _ := Decode([]byte(run(c, "KEYS", "user:?")))
+	assert.Equal(t, 10, len(keys.([]interface{})))
+
+	seen := map[string]bool{}
+	cursor := "0"
+	for {
+		reply, _ := Decode([]byte(run(c, "SCAN", cursor, "MATCH", "user:*", "COUNT", "7", "TYPE", "string")))
+		cursor = reply.([]interface{})[0].(string)
+		for _, k := range reply.([]interface{})[1].([]interface{}) {
+			seen[k.(string)] = true
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+	assert.Equal(t, 100, len(seen))
+	assert.False(t, seen["user:set"])
+
+	reply, _ := Decode([]byte(run(c, "SSCAN", "user:set", "0", "MATCH", "[ab]")))
+	assert.ElementsMatch(t, []interface{}{"a", "b"}, reply.([]interface{})[1])
+	reply, _ = Decode([]byte(run(c, "ZSCAN", "zs", "0")))
+	assert.ElementsMatch(t, []interface{}{"a", "1", "b", "2.5"}, reply.([]interface{})[1])
+	reply, _ = Decode([]byte(run(c, "ZSCAN", "zs", "0", "MATCH", "b")))
+	assert.Equal(t, []interface{}{"b", "2.5"}, reply.([]interface{})[1])
+	assert.Equal(t, "-ERR invalid cursor\r\n", run(c, "SCAN", "x"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(c, "SCAN", "0", "COUNT", "0"))
+}
+
+func TestSelectMoveSwapDB(t *testing.T) {
+	c, other := NewClient(-1), NewClient(-1)
+	assert.Equal(t, "+OK\r\n", run(c, "FLUSHALL"))
+	assert.Equal(t, "-ERR DB index is out of range\r\n", run(c, "SELECT", "16"))
+	assert.Equal(t, "-ERR value is not an integer or out of range\r\n", run(c, "SELECT", "x"))
+
+	assert.Equal(t, "+OK\r\n", run(c, "SET", "db:k", "v"))
+	assert.Equal(t, "-ERR source and destination objects are the same\r\n", run(c, "MOVE", "db:k", "0"))
+	assert.Equal(t, ":1\r\n", run(c, "MOVE", "db:k", "2"))
+	assert.Equal(t, ":0\r\n", run(c, "MOVE", "db:k", "2"))
+	assert.Equal(t, ":0\r\n", run(c, "EXISTS", "db:k"))
+	assert.Equal(t, "+OK\r\n", run(other, "SELECT", "2"))
+	assert.Equal(t, "$1\r\nv\r\n", run(other, "GET", "db:k"))
+	assert.Equal(t, ":0\r\n", run(c, "DBSIZE"))
+	assert.Equal(t, ":1\r\n", run(other, "DBSIZE"))
+	assert.Contains(t, run(c, "INFO"), "db2:keys=1,expires=0")
+
+	assert.Equal(t, "+OK\r\n", run(c, "SWAPDB", "0", "2"))
+	assert.Equal(t, "$1\r\nv\r\n", run(c, "GET", "db:k"))
+	assert.Equal(t, ":0\r\n", run(other, "DBSIZE"))
+	assert.Equal(t, "-ERR invalid first DB index\r\n", run(c, "SWAPDB", "a", "0"))
+
+	assert.Equal(t, ":1\r\n", run(c, "COPY", "db:k", "db:k", "DB", "5"))
+	assert.Equal(t, "+OK\r\n", run(c, "FLUSHALL"))
+}
+
+func TestSetOptions(t *testing.T) {
+	c := NewClient(-1)
+	nilReply := "$-1\r\n"
+	assert.Equal(t, "+OK\r\n", run(c, "SET", "set:lock", "a", "NX", "PX", "100000"))
+	assert.Equal(t, nilReply, run(c, "SET", "set:lock", "b", "NX", "PX", "100000"))
+	assert.Equal(t, "$1\r\na\r\n", run(c, "SET", "set:lock", "b", "NX", "GET"))
+	assert.Equal(t, nilReply, run(c, "SET", "set:missing", "v", "XX"))
+	assert.Equal(t, ":0\r\n", run(c, "EXISTS", "set:missing"))
+
+	assert.Equal(t, "+OK\r\n", run(c, "SET", "set:lock", "c", "KEEPTTL"))
+	assert.Contains(t, []string{":99\r\n", ":100\r\n"}, run(c, "TTL", "set:lock"))
+	assert.Equal(t, "$1\r\nc\r\n", run(c, "SET", "set:lock", "d", "GET"))
+	assert.Equal(t, ":-1\r\n", run(c, "TTL", "set:lock"))
+	assert.Equal(t, "+OK\r\n", run(c, "SET", "set:lock", "e", "EXAT", "99999999999"))
+	assert.Equal(t, "+OK\r\n", run(c, "SET", "set:lock", "e", "EX", "10"))
+	assert.Contains(t, []string{":9\r\n", ":10\r\n"}, run(c, "TTL", "set:lock"))
+
+	syntax := "-ERR syntax error\r\n"
+	assert.Equal(t, syntax, run(c, "SET", "set:k", "v", "NX", "XX"))
+	assert.Equal(t, syntax, run(c, "SET", "set:k", "v", "EX", "1", "PX", "1"))
+	assert.Equal(t, syntax, run(c, "SET", "set:k", "v", "KEEPTTL", "EX", "1"))
+	assert.Equal(t, syntax, run(c, "SET", "set:k", "v", "EX"))
+	assert.Equal(t, syntax, run(c, "SET", "set:k", "v", "1"))
+	assert.Equal(t, "-ERR value is not an integer or out of range\r\n", run(c, "SET", "set:k", "v", "EX", "x"))
+	assert.Equal(t, "-ERR invalid expire time in 'set' command\r\n", run(c, "SET", "set:k", "v", "PX", "0"))
+	assert.Equal(t, "-ERR invalid expire time in 'set' command\r\n", run(c, "SET", "set:k", "v", "EX", "9223372036854775807"))
+
+	assert.Equal(t, ":1\r\n", run(c, "SADD", "set:set", "a"))
+	assert.Equal(t, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", run(c, "SET", "set:set", "v", "GET"))
+	assert.Equal(t, "+OK\r\n", run(c, "SET", "set:set", "v"))
+	assert.Equal(t, ":2\r\n", run(c, "DEL", "set:lock", "set:set", "set:k"))
+}
+
+func TestExpireCommands(t *testing.T) {
+	c := NewClient(-1)
+	assert.Equal(t, ":0\r\n", run(c, "PEXPIRE", "exp:k", "100"))
+	assert.Equal(t, ":-2\r\n", run(c, "PTTL", "exp:k"))
+	assert.Equal(t, "+OK\r\n", run(c, "SET", "exp:k", "v"))
+	assert.Equal(t, ":-1\r\n", run(c, "EXPIRETIME", "exp:k"))
+	assert.Equal(t, ":0\r\n", run(c, "PERSIST", "exp:k"))
+
+	assert.Equal(t, ":0\r\n", run(c, "EXPIRE", "exp:k", "100", "XX"))
+	assert.Equal(t, ":0\r\n", run(c, "EXPIRE", "exp:k", "100", "GT"))
+	assert.Equal(t, ":1\r\n", run(c, "EXPIRE", "exp:k", "100", "NX"))
+	assert.Equal(t, ":0\r\n", run(c, "EXPIRE", "exp:k", "200", "NX"))
+	assert.Equal(t, ":0\r\n", run(c, "EXPIRE", "exp:k", "50", "GT"))
+	assert.Equal(t, ":1\r\n", run(c, "PEXPIRE", "exp:k", "50500", "LT"))
+	pttl, err := strconv.Atoi(strings.Trim(run(c, "PTTL", "exp:k"), ":\r\n"))
+	assert.NoError(t, err)
+	assert.True(t, pttl > 50000 && pttl <= 50500, pttl)
+	assert.Equal(t, ":51\r\n", run(c, "TTL", "exp:k"))
+
+	assert.Equal(t, ":1\r\n", run(c, "EXPIREAT", "exp:k", "32503680000"))
+	assert.Equal(t, ":32503680000\r\n", run(c, "EXPIRETIME", "exp:k"))
+	assert.Equal(t, ":32503680000000\r\n", run(c, "PEXPIRETIME", "exp:k"))
+	assert.Equal(t, ":1\r\n", run(c, "PERSIST", "exp:k"))
+	assert.Equal(t, ":-1\r\n", run(c, "TTL", "exp:k"))
+
+	assert.Equal(t, "-ERR NX and XX, GT or LT options at the same time are not compatible\r\n", run(c, "EXPIRE", "exp:k", "1", "NX", "GT"))
+	assert.Equal(t, "-ERR GT and LT options at the same time are not compatible\r\n", run(c, "EXPIRE", "exp:k", "1", "GT", "LT"))
+	assert.Equal(t, "-ERR Unsupported option FOO\r\n", run(c, "EXPIRE", "exp:k", "1", "FOO"))
+	assert.Equal(t, "-ERR value is not an integer or out of range\r\n", run(c, "PEXPIREAT", "exp:k", "soon"))
+	assert.Equal(t, "-ERR invalid expire time in 'expire' command\r\n", run(c, "EXPIRE", "exp:k", "9223372036854775807"))
+
+	// a time in the past deletes the key
+	assert.Equal(t, ":1\r\n", run(c, "PEXPIREAT", "exp:k", "1"))
+	assert.Equal(t, ":0\r\n", run(c, "EXISTS", "exp:k"))
+}
+
+func TestExpireEveryType(t *testing.T) {
+	c := NewClient(-1)
+	assert.Equal(t, "+OK\r\n", run(c, "FLUSHALL"))
+	assert.Equal(t, ":1\r\n", run(c, "SADD", "ttl:set", "a"))
+	assert.Equal(t, ":1\r\n", run(c, "ZADD", "ttl:zset", "1", "a"))
+	assert.Equal(t, "+OK\r\n", run(c, "BF.RESERVE", "ttl:bf", "0.01", "100"))
+	assert.Equal(t, "+OK\r\n", run(c, "CMS.INITBYDIM", "ttl:cms", "10", "2"))
+	keys := []string{"ttl:set", "ttl:zset", "ttl:bf", "ttl:cms"}
+	for _, key := range keys {
+		assert.Equal(t, ":1\r\n", run(c, "EXPIRE", key, "100"), key)
+		assert.Equal(t, ":100\r\n", run(c, "TTL", key), key)
+	}
+
+	// expire every key, each command family must treat them as missing
+	for _, key := range keys {
+		c.db().SetExpiredAt(key, 1)
+	}
+	assert.Equal(t, "*0\r\n", run(c, "SMEMBERS", "ttl:set"))
+	assert.Equal(t, "$-1\r\n", run(c, "ZSCORE", "ttl:zset", "a"))
+	assert.Equal(t, ":0\r\n", run(c, "BF.EXISTS", "ttl:bf", "a"))
+	assert.Equal(t, "-CMS: key does not exist\r\n", run(c, "CMS.QUERY", "ttl:cms", "a"))
+	assert.Equal(t, 0, c.db().Len())
+
+	// active expiry samples keys of every type
+	assert.Equal(t, ":1\r\n", run(c, "ZADD", "ttl:zset", "1", "a"))
+	assert.Equal(t, "+OK\r\n", run(c, "BF.RESERVE", "ttl:bf", "0.01", "100"))
+	c.db().SetExpiredAt("ttl:zset", 1)
+	c.db().SetExpiredAt("ttl:bf", 1)
+	ActiveDeleteExpiredKeys()
+	assert.Equal(t, 0, c.db().Len())
+	assert.Equal(t, 0, c.db().ExpiresLen())
+}
+
+func TestActiveExpireCycle(t *testing.T) {
+	c := NewClient(-1)
+	run(c, "FLUSHALL")
+	assert.Equal(t, time.Duration(-1), ActiveExpireTimeout(time.Now()))
+
+	before := expiredKeys()
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("cycle:%d", i)
+		run(c, "SET", key, "v")
+		c.db().SetExpiredAt(key, uint64(i+1))
+	}
+	run(c, "SET", "cycle:later", "v", "EX", "100")
+	assert.Equal(t, time.Duration(0), ActiveExpireTimeout(time.Now().Add(-time.Second)))
+
+	ActiveDeleteExpiredKeys()
+	assert.Equal(t, ":1\r\n", run(c, "DBSIZE"))
+	assert.Equal(t, int64(1000), expiredKeys()-before)
+	assert.Contains(t, run(c, "INFO"), "expired_keys:")
+
+	timeout := ActiveExpireTimeout(time.Now())
+	assert.True(t, timeout > 99*time.Second && timeout <= 100*time.Second, timeout)
+	run(c, "FLUSHALL")
+}
+
+func TestMaxMemory(t *testing.T) {
+	defer func(n int64, policy string) {
+		config.MaxMemory, config.EvictionPolicy = n, policy
+	}(config.MaxMemory, config.EvictionPolicy)
+	c := NewClient(-1)
+	run(c, "FLUSHALL")
+	empty := usedMemory()
+
+	value := strings.Repeat("x", 1000)
+	assert.Equal(t, "+OK\r\n", run(c, "SET", "mem:str", value))
+	assert.Equal(t, ":1095\r\n", run(c, "MEMORY", "USAGE", "mem:str"))
+	assert.Equal(t, ":1095\r\n", run(c, "MEMORY", "USAGE", "mem:str", "SAMPLES", "5"))
+	assert.Equal(t, ":1095\r\n", run(c, "MEMORY", "USAGE", "mem:str", "samples", "0"))
+	assert.Equal(t, "-ERR value is not an integer or out of range\r\n", run(c, "MEMORY", "USAGE", "mem:str", "SAMPLES", "-1"))
+	assert.Equal(t, "$-1\r\n", run(c, "MEMORY", "USAGE", "mem:missing"))
+	// inspecting the key is not an access
+	c.db().GetNoTouch("mem:str").SetIdleTime(10 * time.Second)
+	run(c, "MEMORY", "USAGE", "mem:str")
+	assert.Equal(t, ":10\r\n", run(c, "OBJECT", "IDLETIME", "mem:str"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(c, "MEMORY", "USAGE", "mem:str", "FOO", "5"))
+
+	// members added in place are accounted
+	run(c, "SADD", "mem:set", "a")
+	before := usedMemory()
+	run(c, "SADD", "mem:set", value)
+	assert.Equal(t, before+1024, usedMemory())
+	run(c, "SREM", "mem:set", value)
+	assert.Equal(t, before, usedMemory())
+	assert.Contains(t, run(c, "MEMORY", "STATS"), "keys.count")
+
+	config.EvictionPolicy = "allkeys-random"
+	config.MaxMemory = empty + 20*1100
+	for i := 0; i < 100; i++ {
+		run(c, "SET", fmt.Sprintf("mem:%d", i), value)
+		assert.True(t, usedMemory() <= config.MaxMemory+1100)
+	}
+	assert.True(t, evictStats.evictedKeys > 0)
+	run(c, "PING")
+	assert.True(t, usedMemory() <= config.MaxMemory)
+
+	// nothing can be evicted, commands growing memory are refused but others still run
+	config.EvictionPolicy = "unknown"
+	config.MaxMemory = 1
+	assert.Equal(t, "-OOM command not allowed when used memory > 'maxmemory'.\r\n", run(c, "SET", "mem:k", "v"))
+	assert.Equal(t, "$-1\r\n", run(c, "GET", "mem:k"))
+	config.MaxMemory = 0
+	run(c, "FLUSHALL")
+}
+
+func TestVolatileEviction(t *testing.T) {
+	defer func(n int64, policy string) {
+		config.MaxMemory, config.EvictionPolicy = n, policy
+	}(config.MaxMemory, config.EvictionPolicy)
+	c := NewClient(-1)
+	run(c, "FLUSHALL")
+	value := strings.Repeat("x", 1000)
+	for i := 0; i < 5; i++ {
+		run(c, "SET", fmt.Sprintf("evict:persistent:%d", i), value)
+	}
+	run(c, "SET", "evict:later", value, "EX", "200")
+	run(c, "SET", "evict:sooner", value, "EX", "100")
+	run(c, "SADD", "evict:set", value)
+	run(c, "EXPIRE", "evict:set", "300")
+
+	for _, policy := range []string{"volatile-ttl", "volatile-random", "volatile-lru", "volatile-lfu"} {
+		config.EvictionPolicy = policy
+		config.MaxMemory = usedMemory() - 1
+		assert.Equal(t, "+PONG\r\n", run(c, "PING"), policy)
+		assert.Equal(t, ":5\r\n", run(c, "EXISTS", "evict:persistent:0", "evict:persistent:1",
+			"evict:persistent:2", "evict:persistent:3", "evict:persistent:4"), policy)
+		if policy == "volatile-ttl" {
+			assert.Equal(t, ":0\r\n", run(c, "EXISTS", "evict:sooner"))
+		}
+	}
+	assert.Equal(t, ":5\r\n", run(c, "DBSIZE"))
+
+	// only keys without TTL are left, nothing can be evicted
+	config.MaxMemory = usedMemory() - 1
+	oom := "-OOM command not allowed when used memory > 'maxmemory'.\r\n"
+	assert.Equal(t, oom, run(c, "SET", "evict:k", "v"))
+	config.EvictionPolicy = "noeviction"
+	assert.Equal(t, oom, run(c, "SADD", "evict:s", "v"))
+	assert.Equal(t, ":1\r\n", run(c, "DEL", "evict:persistent:0"))
+	assert.Equal(t, "+OK\r\n", run(c, "SET", "evict:k", "v"))
+	config.MaxMemory = 0
+	run(c, "FLUSHALL")
+}
+
+func TestObjectFreqAndIdleTime(t *testing.T) {
+	defer func(policy string, samples int) {
+		config.EvictionPolicy, config.MaxMemorySamples = policy, samples
+	}(config.EvictionPolicy, config.MaxMemorySamples)
+	c := NewClient(-1)
+	run(c, "FLUSHALL")
+
+	config.EvictionPolicy = "allkeys-lru"
+	run(c, "SET", "obj:k", "v")
+	assert.Equal(t, ":0\r\n", run(c, "OBJECT", "IDLETIME", "obj:k"))
+	assert.Equal(t, "$-1\r\n", run(c, "OBJECT", "IDLETIME", "obj:missing"))
+	assert.True(t, strings.HasPrefix(run(c, "OBJECT", "FREQ", "obj:k"), "-ERR An LFU maxmemory policy is not selected"))
+
+	config.EvictionPolicy = "allkeys-lfu"
+	assert.True(t, strings.HasPrefix(run(c, "OBJECT", "IDLETIME", "obj:k"), "-ERR An LFU maxmemory policy is selected"))
+	value := strings.Repeat("x", 100)
+	for i := 0; i < 20; i++ {
+		run(c, "SET", fmt.Sprintf("obj:%d", i), value)
+	}
+	assert.Equal(t, ":5\r\n", run(c, "OBJECT", "FREQ", "obj:0"))
+	// the counter grows logarithmically
+	for i := 0; i < 1000; i++ {
+		for j := 0; j < 10; j++ {
+			run(c, "GET", fmt.Sprintf("obj:%d", j))
+		}
+	}
+	freq, err := strconv.Atoi(strings.Trim(run(c, "OBJECT", "FREQ", "obj:0"), ":\r\n"))
+	assert.NoError(t, err)
+	assert.True(t, freq > 5 && freq < 30, freq)
+
+	// the keys that were never read are evicted first
+	config.MaxMemorySamples = 20
+	defer func(n int64) { config.MaxMemory = n }(config.MaxMemory)
+	config.MaxMemory = usedMemory() - 5*200
+	run(c, "PING")
+	assert.Equal(t, ":10\r\n", run(c, "EXISTS", "obj:0", "obj:1", "obj:2", "obj:3", "obj:4",
+		"obj:5", "obj:6", "obj:7", "obj:8", "obj:9"))
+	config.MaxMemory = 0
+	run(c, "FLUSHALL")
+}
+
+func TestObjectEncoding(t *testing.T) {
+	defer func(sets, zsets int) {
+		config.SetMaxIntsetEntries, config.ZsetMaxListpackEntries = sets, zsets
+	}(config.SetMaxIntsetEntries, config.ZsetMaxListpackEntries)
+	config.SetMaxIntsetEntries, config.ZsetMaxListpackEntries = 4, 4
+	c := NewClient(-1)
+	run(c, "FLUSHALL")
+	encoding := func(key string) string {
+		return run(c, "OBJECT", "ENCODING", key)
+	}
+
+	run(c, "SET", "enc:int", "-12345")
+	run(c, "SET", "enc:embstr", "012")
+	run(c, "SET", "enc:raw", strings.Repeat("x", 45))
+	assert.Equal(t, "$3\r\nint\r\n", encoding("enc:int"))
+	assert.Equal(t, "$6\r\nembstr\r\n", encoding("enc:embstr"))
+	assert.Equal(t, "$3\r\nraw\r\n", encoding("enc:raw"))
+	assert.Equal(t, ":1\r\n", run(c, "OBJECT", "REFCOUNT", "enc:int"))
+	assert.Equal(t, "$-1\r\n", encoding("enc:missing"))
+
+	run(c, "SADD", "enc:set", "3", "1", "2")
+	assert.Equal(t, "$6\r\nintset\r\n", encoding("enc:set"))
+	assert.Equal(t, "*3\r\n$1\r\n1\r\n$1\r\n2\r\n$1\r\n3\r\n", run(c, "SMEMBERS", "enc:set"))
+	run(c, "SADD", "enc:set", "4")
+	assert.Equal(t, "$6\r\nintset\r\n", encoding("enc:set"))
+	// too many members
+	run(c, "SADD", "enc:set", "5")
+	assert.Equal(t, "$9\r\nhashtable\r\n", encoding("enc:set"))
+	assert.Equal(t, ":1\r\n", run(c, "SISMEMBER", "enc:set", "5"))
+	run(c, "SADD", "enc:set2", "1", "a")
+	assert.Equal(t, "$9\r\nhashtable\r\n", encoding("enc:set2"))
+
+	run(c, "ZADD", "enc:zset", "3", "c", "1", "a", "2", "b")
+	assert.Equal(t, "$8\r\nlistpack\r\n", encoding("enc:zset"))
+	assert.Equal(t, ":1\r\n", run(c, "ZRANK", "enc:zset", "b"))
+	run(c, "ZADD", "enc:zset", "0", "b")
+	assert.Equal(t, ":0\r\n", run(c, "ZRANK", "enc:zset", "b"))
+	run(c, "ZADD", "enc:zset", "4", "d", "5", "e")
+	assert.Equal(t, "$9\r\nbplustree\r\n", encoding("enc:zset"))
+	assert.Equal(t, ":4\r\n", run(c, "ZRANK", "enc:zset", "e"))
+	assert.Equal(t, "$1\r\n3\r\n", run(c, "ZSCORE", "enc:zset", "c"))
+	run(c, "FLUSHALL")
+}
+
+func TestLazyFree(t *testing.T) {
+	c := NewClient(-1)
+	run(c, "FLUSHALL")
+	empty := usedMemory()
+	freed := data_structure.LazyfreedObjects()
+	addMembers := func(key string, n int) {
+		args := []string{"SADD", key}
+		for i := 0; i < n; i++ {
+			args = append(args, fmt.Sprintf("m%d", i))
+		}
+		run(c, args...)
+	}
+
+	addMembers("lazy:big", 1000)
+	addMembers("lazy:small", 10)
+	run(c, "SET", "lazy:str", "v")
+	assert.Equal(t, ":3\r\n", run(c, "UNLINK", "lazy:big", "lazy:small", "lazy:str", "lazy:missing"))
+	assert.Equal(t, ":0\r\n", run(c, "DBSIZE"))
+	assert.Equal(t, empty, usedMemory())
+	// only the big set is worth releasing in the background
+	assert.Eventually(t, func() bool {
+		return data_structure.LazyfreedObjects() == freed+1
+	}, time.Second, time.Millisecond)
+
+	addMembers("lazy:big", 1000)
+	run(c, "SELECT", "1")
+	run(c, "SET", "lazy:str", "v")
+	assert.Equal(t, "-ERR syntax error\r\n", run(c, "FLUSHALL", "LATER"))
+	assert.Equal(t, "+OK\r\n", run(c, "FLUSHALL", "ASYNC"))
+	assert.Equal(t, ":0\r\n", run(c, "DBSIZE"))
+	run(c, "SELECT", "0")
+	assert.Equal(t, ":0\r\n", run(c, "DBSIZE"))
+	assert.Eventually(t, func() bool {
+		return data_structure.LazyfreePendingObjects() == 0
+	}, time.Second, time.Millisecond)
+	assert.Contains(t, run(c, "INFO"), "lazyfree_pending_objects:0")
+}
+
+func TestDumpRestore(t *testing.T) {
+	c := NewClient(-1)
+	run(c, "FLUSHALL")
+	dump := func(key string) string {
+		res := run(c, "DUMP", key)
+		header, payload, _ := strings.Cut(res, "\r\n")
+		n, err := strconv.Atoi(header[1:])
+		assert.NoError(t, err)
+		return payload[:n]
+	}
+
+	run(c, "SET", "dump:str", "hello")
+	run(c, "SADD", "dump:set", "1", "2", "a")
+	run(c, "ZADD", "dump:zset", "1", "a", "2", "b")
+	run(c, "CMS.INITBYDIM", "dump:cms", "100", "4")
+	run(c, "CMS.INCRBY", "dump:cms", "x", "3")
+	run(c, "BF.RESERVE", "dump:bf", "0.01", "100")
+	run(c, "BF.MADD", "dump:bf", "x")
+	run(c, "RPUSH", "dump:list", "a", "b")
+	assert.Equal(t, "$-1\r\n", run(c, "DUMP", "dump:missing"))
+
+	for _, key := range []string{"dump:str", "dump:set", "dump:zset", "dump:cms", "dump:bf", "dump:list"} {
+		payload := dump(key)
+		assert.Equal(t, "-BUSYKEY Target key name already exists.\r\n", run(c, "RESTORE", key, "0", payload))
+		assert.Equal(t, "+OK\r\n", run(c, "RESTORE", key+":copy", "0", payload))
+	}
+	assert.Equal(t, "$5\r\nhello\r\n", run(c, "GET", "dump:str:copy"))
+	assert.Equal(t, ":1\r\n", run(c, "SISMEMBER", "dump:set:copy", "a"))
+	assert.Equal(t, "$1\r\n2\r\n", run(c, "ZSCORE", "dump:zset:copy", "b"))
+	assert.Equal(t, "*1\r\n$1\r\n3\r\n", run(c, "CMS.QUERY", "dump:cms:copy", "x"))
+	assert.Equal(t, ":1\r\n", run(c, "BF.EXISTS", "dump:bf:copy", "x"))
+	assert.Equal(t, "*2\r\n$1\r\na\r\n$1\r\nb\r\n", run(c, "LRANGE", "dump:list:copy", "0", "-1"))
+
+	payload := dump("dump:str")
+	corrupt := payload[:len(payload)-1] + string(payload[len(payload)-1]^1)
+	assert.Equal(t, "-ERR DUMP payload version or checksum are wrong\r\n", run(c, "RESTORE", "dump:k", "0", corrupt))
+	assert.Equal(t, "-ERR Invalid TTL value, must be >= 0\r\n", run(c, "RESTORE", "dump:k", "-1", payload))
+	assert.Equal(t, "-ERR invalid expire time in 'restore' command\r\n", run(c, "RESTORE", "dump:k", "9223372036854775807", payload))
+	assert.Equal(t, ":0\r\n", run(c, "EXISTS", "dump:k"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(c, "RESTORE", "dump:k", "0", payload, "IDLETIME", "1", "FREQ", "1"))
+
+	// the TTL is relative unless ABSTTL is given, a deadline in the past restores nothing
+	assert.Equal(t, "+OK\r\n", run(c, "RESTORE", "dump:str", "10000", payload, "REPLACE", "IDLETIME", "100"))
+	assert.Equal(t, ":100\r\n", run(c, "OBJECT", "IDLETIME", "dump:str"))
+	ttl, err := strconv.Atoi(strings.Trim(run(c, "PTTL", "dump:str"), ":\r\n"))
+	assert.NoError(t, err)
+	assert.True(t, ttl > 9000 && ttl <= 10000, ttl)
+	assert.Equal(t, "+OK\r\n", run(c, "RESTORE", "dump:str", "1", payload, "REPLACE", "ABSTTL"))
+	assert.Equal(t, ":0\r\n", run(c, "EXISTS", "dump:str"))
+	run(c, "FLUSHALL")
+}
+
+func TestCounters(t *testing.T) {
+	c := NewClient(-1)
+	run(c, "FLUSHALL")
+
+	assert.Equal(t, ":1\r\n", run(c, "INCR", "cnt:a"))
+	assert.Equal(t, ":11\r\n", run(c, "INCRBY", "cnt:a", "10"))
+	assert.Equal(t, ":10\r\n", run(c, "DECR", "cnt:a"))
+	assert.Equal(t, ":-5\r\n", run(c, "DECRBY", "cnt:a", "15"))
+	assert.Equal(t, "$2\r\n-5\r\n", run(c, "GET", "cnt:a"))
+	assert.Equal(t, "$3\r\nint\r\n", run(c, "OBJECT", "ENCODING", "cnt:a"))
+
+	// counters keep their TTL
+	run(c, "EXPIRE", "cnt:a", "100")
+	run(c, "INCR", "cnt:a")
+	assert.Equal(t, ":100\r\n", run(c, "TTL", "cnt:a"))
+
+	run(c, "SET", "cnt:max", "9223372036854775807")
+	assert.Equal(t, "-ERR increment or decrement would overflow\r\n", run(c, "INCR", "cnt:max"))
+	assert.Equal(t, "-ERR decrement would overflow\r\n", run(c, "DECRBY", "cnt:a", "-9223372036854775808"))
+	run(c, "SET", "cnt:str", "12a")
+	assert.Equal(t, "-ERR value is not an integer or out of range\r\n", run(c, "INCR", "cnt:str"))
+	run(c, "SET", "cnt:str", " 12")
+	assert.Equal(t, "-ERR value is not an integer or out of range\r\n", run(c, "INCR", "cnt:str"))
+	assert.Equal(t, "-ERR value is not an integer or out of range\r\n", run(c, "INCRBY", "cnt:a", "1.5"))
+	run(c, "SADD", "cnt:set", "a")
+	assert.Equal(t, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", run(c, "INCR", "cnt:set"))
+
+	assert.Equal(t, "$4\r\n10.5\r\n", run(c, "INCRBYFLOAT", "cnt:f", "10.5"))
+	assert.Equal(t, "$4\r\n10.6\r\n", run(c, "INCRBYFLOAT", "cnt:f", "0.1"))
+	assert.Equal(t, "$6\r\n5010.6\r\n", run(c, "INCRBYFLOAT", "cnt:f", "5.0e3"))
+	assert.Equal(t, "$1\r\n3\r\n", run(c, "INCRBYFLOAT", "cnt:b", "3"))
+	assert.Equal(t, "$6\r\nembstr\r\n", run(c, "OBJECT", "ENCODING", "cnt:b"))
+	assert.Equal(t, ":4\r\n", run(c, "INCR", "cnt:b"))
+	assert.Equal(t, "-ERR value is not a valid float\r\n", run(c, "INCRBYFLOAT", "cnt:f", "abc"))
+	assert.Equal(t, "-ERR value is not a valid float\r\n", run(c, "INCRBYFLOAT", "cnt:str", "1"))
+	assert.Equal(t, "-ERR increment would produce NaN or Infinity\r\n", run(c, "INCRBYFLOAT", "cnt:f", "inf"))
+	run(c, "FLUSHALL")
+}
+
+func TestStringCommands(t *testing.T) {
+	defer func(n int64) { config.ProtoMaxBulkLen = n }(config.ProtoMaxBulkLen)
+	c := NewClient(-1)
+	run(c, "FLUSHALL")
+
+	assert.Equal(t, ":5\r\n", run(c, "APPEND", "str:a", "Hello"))
+	assert.Equal(t, ":11\r\n", run(c, "APPEND", "str:a", " World"))
+	assert.Equal(t, ":11\r\n", run(c, "STRLEN", "str:a"))
+	assert.Equal(t, ":0\r\n", run(c, "STRLEN", "str:missing"))
+	run(c, "SET", "str:n", "123")
+	assert.Equal(t, ":3\r\n", run(c, "STRLEN", "str:n"))
+	assert.Equal(t, ":4\r\n", run(c, "APPEND", "str:n", "4"))
+	assert.Equal(t, "$4\r\n1234\r\n", run(c, "GET", "str:n"))
+	// an appended log is changed in place, a copy of it is not
+	for i := 0; i < 1000; i++ {
+		run(c, "APPEND", "str:log", "entry\n")
+	}
+	run(c, "COPY", "str:log", "str:log2")
+	run(c, "SETRANGE", "str:log", "0", "ENTRY")
+	run(c, "APPEND", "str:log", "last")
+	assert.Equal(t, ":6004\r\n", run(c, "STRLEN", "str:log"))
+	assert.Equal(t, "$9\r\nENTRY\nent\r\n", run(c, "GETRANGE", "str:log", "0", "8"))
+	assert.Equal(t, "$9\r\nentry\nent\r\n", run(c, "GETRANGE", "str:log2", "0", "8"))
+	assert.Equal(t, ":6000\r\n", run(c, "STRLEN", "str:log2"))
+
+	run(c, "SET", "str:r", "This is a string")
+	assert.Equal(t, "$4\r\nThis\r\n", run(c, "GETRANGE", "str:r", "0", "3"))
+	assert.Equal(t, "$3\r\ning\r\n", run(c, "GETRANGE", "str:r", "-3", "-1"))
+	assert.Equal(t, "$16\r\nThis is a string\r\n", run(c, "GETRANGE", "str:r", "0", "-1"))
+	assert.Equal(t, "$6\r\nstring\r\n", run(c, "GETRANGE", "str:r", "10", "100"))
+	assert.Equal(t, "$0\r\n\r\n", run(c, "GETRANGE", "str:r", "5", "2"))
+	assert.Equal(t, "$0\r\n\r\n", run(c, "GETRANGE", "str:r", "-1", "-5"))
+	assert.Equal(t, "$0\r\n\r\n", run(c, "GETRANGE", "str:missing", "0", "-1"))
+
+	run(c, "SET", "str:s", "Hello World")
+	assert.Equal(t, ":11\r\n", run(c, "SETRANGE", "str:s", "6", "Redis"))
+	assert.Equal(t, "$11\r\nHello Redis\r\n", run(c, "GET", "str:s"))
+	assert.Equal(t, ":11\r\n", run(c, "SETRANGE", "str:pad", "6", "Redis"))
+	assert.Equal(t, "$11\r\n\x00\x00\x00\x00\x00\x00Redis\r\n", run(c, "GET", "str:pad"))
+	assert.Equal(t, ":0\r\n", run(c, "SETRANGE", "str:empty", "5", ""))
+	assert.Equal(t, ":0\r\n", run(c, "EXISTS", "str:empty"))
+	assert.Equal(t, "-ERR offset is out of range\r\n", run(c, "SETRANGE", "str:s", "-1", "x"))
+	config.ProtoMaxBulkLen = 20
+	assert.Equal(t, "-ERR string exceeds maximum allowed size (proto-max-bulk-len)\r\n", run(c, "SETRANGE", "str:s", "20", "x"))
+	assert.Equal(t, "-ERR string exceeds maximum allowed size (proto-max-bulk-len)\r\n", run(c, "APPEND", "str:s", strings.Repeat("x", 10)))
+	config.ProtoMaxBulkLen = 512 * 1024 * 1024
+
+	run(c, "SET", "str:g", "old", "EX", "100")
+	assert.Equal(t, "$3\r\nold\r\n", run(c, "GETSET", "str:g", "new"))
+	assert.Equal(t, ":-1\r\n", run(c, "TTL", "str:g"))
+	assert.Equal(t, "$-1\r\n", run(c, "GETSET", "str:g2", "v"))
+	assert.Equal(t, "$3\r\nnew\r\n", run(c, "GETDEL", "str:g"))
+	assert.Equal(t, "$-1\r\n", run(c, "GETDEL", "str:g"))
+
+	run(c, "SET", "str:e", "v")
+	assert.Equal(t, "$1\r\nv\r\n", run(c, "GETEX", "str:e", "EX", "100"))
+	assert.Equal(t, ":100\r\n", run(c, "TTL", "str:e"))
+	assert.Equal(t, "$1\r\nv\r\n", run(c, "GETEX", "str:e", "PERSIST"))
+	assert.Equal(t, ":-1\r\n", run(c, "TTL", "str:e"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(c, "GETEX", "str:e", "EX", "1", "PERSIST"))
+	assert.Equal(t, "-ERR invalid expire time in 'getex' command\r\n", run(c, "GETEX", "str:e", "PX", "0"))
+	assert.Equal(t, "$1\r\nv\r\n", run(c, "GETEX", "str:e", "PXAT", "1"))
+	assert.Equal(t, ":0\r\n", run(c, "EXISTS", "str:e"))
+
+	assert.Equal(t, ":1\r\n", run(c, "SETNX", "str:nx", "a"))
+	assert.Equal(t, ":0\r\n", run(c, "SETNX", "str:nx", "b"))
+	assert.Equal(t, "$1\r\na\r\n", run(c, "GET", "str:nx"))
+	assert.Equal(t, "+OK\r\n", run(c, "SETEX", "str:ex", "100", "v"))
+	assert.Equal(t, ":100\r\n", run(c, "TTL", "str:ex"))
+	assert.Equal(t, "+OK\r\n", run(c, "PSETEX", "str:ex", "100000", "v"))
+	assert.Equal(t, ":100\r\n", run(c, "TTL", "str:ex"))
+	assert.Equal(t, "-ERR invalid expire time in 'setex' command\r\n", run(c, "SETEX", "str:ex", "0", "v"))
+
+	run(c, "SADD", "str:set", "a")
+	for _, args := range [][]string{{"APPEND", "str:set", "x"}, {"STRLEN", "str:set"}, {"GETRANGE", "str:set", "0", "1"},
+		{"SETRANGE", "str:set", "0", "x"}, {"GETDEL", "str:set"}, {"GETEX", "str:set"}, {"GETSET", "str:set", "x"}} {
+		assert.Equal(t, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", run(c, args...))
+	}
+	run(c, "FLUSHALL")
+}
+
+func TestLCS(t *testing.T) {
+	c := NewClient(-1)
+	run(c, "FLUSHALL")
+	run(c, "SET", "lcs:a", "ohmytext")
+	run(c, "SET", "lcs:b", "mynewtext")
+
+	assert.Equal(t, "$6\r\nmytext\r\n", run(c, "LCS", "lcs:a", "lcs:b"))
+	assert.Equal(t, ":6\r\n", run(c, "LCS", "lcs:a", "lcs:b", "LEN"))
+	assert.Equal(t, "*4\r\n$7\r\nmatches\r\n*2\r\n"+
+		"*2\r\n*2\r\n:4\r\n:7\r\n*2\r\n:5\r\n:8\r\n"+
+		"*2\r\n*2\r\n:2\r\n:3\r\n*2\r\n:0\r\n:1\r\n"+
+		"$3\r\nlen\r\n:6\r\n", run(c, "LCS", "lcs:a", "lcs:b", "IDX"))
+	assert.Equal(t, "*4\r\n$7\r\nmatches\r\n*1\r\n"+
+		"*3\r\n*2\r\n:4\r\n:7\r\n*2\r\n:5\r\n:8\r\n:4\r\n"+
+		"$3\r\nlen\r\n:6\r\n", run(c, "LCS", "lcs:a", "lcs:b", "IDX", "MINMATCHLEN", "4", "WITHMATCHLEN"))
+	assert.Equal(t, "$0\r\n\r\n", run(c, "LCS", "lcs:a", "lcs:missing"))
+	assert.Equal(t, "-ERR If you want both the length and indexes, please just use IDX.\r\n", run(c, "LCS", "lcs:a", "lcs:b", "LEN", "IDX"))
+	run(c, "SADD", "lcs:set", "a")
+	assert.Equal(t, "-ERR The specified keys must contain string values\r\n", run(c, "LCS", "lcs:a", "lcs:set"))
+	run(c, "FLUSHALL")
+}
+
+func TestMultiKeyStrings(t *testing.T) {
+	c := NewClient(-1)
+	run(c, "FLUSHALL")
+	empty := usedMemory()
+
+	assert.Equal(t, "+OK\r\n", run(c, "MSET", "m:a", "1", "m:b", "two"))
+	assert.Equal(t, "-ERR wrong number of arguments for 'mset' command\r\n", run(c, "MSET", "m:a", "1", "m:b"))
+	run(c, "SADD", "m:set", "x")
+	assert.Equal(t, "*4\r\n$1\r\n1\r\n$3\r\ntwo\r\n$-1\r\n$-1\r\n", run(c, "MGET", "m:a", "m:b", "m:set", "m:missing"))
+	// every key of the batch is accounted
+	var size int64
+	for _, key := range []string{"m:a", "m:b", "m:set"} {
+		n, _ := c.db().MemoryUsage(key)
+		size += n
+	}
+	assert.Equal(t, empty+size, usedMemory())
+
+	assert.Equal(t, ":0\r\n", run(c, "MSETNX", "m:c", "3", "m:a", "4"))
+	assert.Equal(t, ":0\r\n", run(c, "EXISTS", "m:c"))
+	assert.Equal(t, ":1\r\n", run(c, "MSETNX", "m:c", "3", "m:d", "4"))
+	assert.Equal(t, "*2\r\n$1\r\n3\r\n$1\r\n4\r\n", run(c, "MGET", "m:c", "m:d"))
+	assert.Equal(t, "-ERR wrong number of arguments for 'msetnx' command\r\n", run(c, "MSETNX", "m:e", "1", "m:f"))
+	run(c, "FLUSHALL")
+}
+
+func TestBitmap(t *testing.T) {
+	c := NewClient(-1)
+	run(c, "FLUSHALL")
+
+	assert.Equal(t, ":0\r\n", run(c, "SETBIT", "bit:a", "7", "1"))
+	assert.Equal(t, ":1\r\n", run(c, "SETBIT", "bit:a", "7", "0"))
+	assert.Equal(t, ":0\r\n", run(c, "SETBIT", "bit:a", "1", "1"))
+	assert.Equal(t, ":0\r\n", run(c, "SETBIT", "bit:a", "20", "1"))
+	assert.Equal(t, "$3\r\n\x40\x00\x08\r\n", run(c, "GET", "bit:a"))
+	// the bitmap is changed in place, and accounted with its spare capacity
+	assert.Equal(t, "$3\r\nraw\r\n", run(c, "OBJECT", "ENCODING", "bit:a"))
+	run(c, "SETBIT", "bit:big", "8000000", "1")
+	before, _ := c.db().MemoryUsage("bit:big")
+	assert.Equal(t, ":0\r\n", run(c, "SETBIT", "bit:big", "8000008", "1"))
+	after, _ := c.db().MemoryUsage("bit:big")
+	assert.Equal(t, before, after)
+	assert.Equal(t, ":1000002\r\n", run(c, "STRLEN", "bit:big"))
+	run(c, "DEL", "bit:big")
+	assert.Equal(t, ":1\r\n", run(c, "GETBIT", "bit:a", "1"))
+	assert.Equal(t, ":0\r\n", run(c, "GETBIT", "bit:a", "1000"))
+	assert.Equal(t, ":0\r\n", run(c, "GETBIT", "bit:missing", "0"))
+	assert.Equal(t, "-ERR bit is not an integer or out of range\r\n", run(c, "SETBIT", "bit:a", "0", "2"))
+	assert.Equal(t, "-ERR bit offset is not an integer or out of range\r\n", run(c, "SETBIT", "bit:a", "-1", "1"))
+
+	run(c, "SET", "bit:s", "foobar")
+	assert.Equal(t, ":26\r\n", run(c, "BITCOUNT", "bit:s"))
+	assert.Equal(t, ":4\r\n", run(c, "BITCOUNT", "bit:s", "0", "0"))
+	assert.Equal(t, ":6\r\n", run(c, "BITCOUNT", "bit:s", "1", "1"))
+	assert.Equal(t, ":18\r\n", run(c, "BITCOUNT", "bit:s", "1", "-2"))
+	assert.Equal(t, ":17\r\n", run(c, "BITCOUNT", "bit:s", "5", "30", "BIT"))
+	assert.Equal(t, ":0\r\n", run(c, "BITCOUNT", "bit:missing"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(c, "BITCOUNT", "bit:s", "1"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(c, "BITCOUNT", "bit:s", "0", "1", "WORD"))
+
+	run(c, "SET", "bit:p", "\xff\xf0\x00")
+	assert.Equal(t, ":12\r\n", run(c, "BITPOS", "bit:p", "0"))
+	run(c, "SET", "bit:p", "\x00\xff\xf0")
+	assert.Equal(t, ":8\r\n", run(c, "BITPOS", "bit:p", "1", "0"))
+	assert.Equal(t, ":16\r\n", run(c, "BITPOS", "bit:p", "1", "2"))
+	assert.Equal(t, ":16\r\n", run(c, "BITPOS", "bit:p", "1", "2", "-1", "BYTE"))
+	assert.Equal(t, ":8\r\n", run(c, "BITPOS", "bit:p", "1", "7", "15", "BIT"))
+	assert.Equal(t, ":9\r\n", run(c, "BITPOS", "bit:p", "1", "9", "15", "BIT"))
+	run(c, "SET", "bit:p", "\x00\x00\x00")
+	assert.Equal(t, ":-1\r\n", run(c, "BITPOS", "bit:p", "1"))
+	run(c, "SET", "bit:p", "\xff\xff\xff")
+	assert.Equal(t, ":24\r\n", run(c, "BITPOS", "bit:p", "0"))
+	assert.Equal(t, ":-1\r\n", run(c, "BITPOS", "bit:p", "0", "0", "-1"))
+	assert.Equal(t, ":0\r\n", run(c, "BITPOS", "bit:missing", "0"))
+	assert.Equal(t, ":-1\r\n", run(c, "BITPOS", "bit:missing", "1"))
+	assert.Equal(t, "-ERR The bit argument must be 1 or 0.\r\n", run(c, "BITPOS", "bit:p", "2"))
+
+	// BIT ranges are read without changing a bitmap stored in place
+	run(c, "SETBIT", "bit:m", "0", "1")
+	run(c, "SETBIT", "bit:m", "15", "1")
+	assert.Equal(t, ":0\r\n", run(c, "BITCOUNT", "bit:m", "1", "14", "BIT"))
+	assert.Equal(t, ":2\r\n", run(c, "BITCOUNT", "bit:m", "0", "15", "BIT"))
+	assert.Equal(t, ":15\r\n", run(c, "BITPOS", "bit:m", "1", "1", "15", "BIT"))
+	assert.Equal(t, ":1\r\n", run(c, "BITPOS", "bit:m", "0", "0", "15", "BIT"))
+	assert.Equal(t, "$2\r\n\x80\x01\r\n", run(c, "GET", "bit:m"))
+	run(c, "SETBIT", "bit:z", "7", "0")
+	assert.Equal(t, ":4\r\n", run(c, "BITPOS", "bit:z", "0", "4", "5", "BIT"))
+	assert.Equal(t, ":-1\r\n", run(c, "BITPOS", "bit:z", "1", "4", "5", "BIT"))
+	assert.Equal(t, "$1\r\n\x00\r\n", run(c, "GET", "bit:z"))
+
+	run(c, "SET", "bit:k1", "foobar")
+	run(c, "SET", "bit:k2", "abcdef")
+	assert.Equal(t, ":6\r\n", run(c, "BITOP", "AND", "bit:dst", "bit:k1", "bit:k2"))
+	assert.Equal(t, "$6\r\n`bc`ab\r\n", run(c, "GET", "bit:dst"))
+	run(c, "SET", "bit:long", "0123456789abcdef!")
+	run(c, "SET", "bit:short", "\x01")
+	assert.Equal(t, ":17\r\n", run(c, "BITOP", "OR", "bit:dst", "bit:long", "bit:short", "bit:missing"))
+	assert.Equal(t, "$17\r\n1123456789abcdef!\r\n", run(c, "GET", "bit:dst"))
+	assert.Equal(t, ":17\r\n", run(c, "BITOP", "AND", "bit:dst", "bit:long", "bit:short"))
+	assert.Equal(t, "$17\r\n"+strings.Repeat("\x00", 17)+"\r\n", run(c, "GET", "bit:dst"))
+	assert.Equal(t, ":17\r\n", run(c, "BITOP", "XOR", "bit:dst", "bit:long", "bit:long"))
+	assert.Equal(t, ":0\r\n", run(c, "BITCOUNT", "bit:dst"))
+	assert.Equal(t, ":1\r\n", run(c, "BITOP", "NOT", "bit:dst", "bit:short"))
+	assert.Equal(t, "$1\r\n\xfe\r\n", run(c, "GET", "bit:dst"))
+	assert.Equal(t, ":0\r\n", run(c, "BITOP", "OR", "bit:dst", "bit:missing"))
+	assert.Equal(t, ":0\r\n", run(c, "EXISTS", "bit:dst"))
+	assert.Equal(t, "-ERR BITOP NOT must be called with a single source key.\r\n", run(c, "BITOP", "NOT", "bit:dst", "bit:k1", "bit:k2"))
+	run(c, "SADD", "bit:set", "a")
+	assert.Equal(t, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", run(c, "BITOP", "OR", "bit:dst", "bit:k1", "bit:set"))
+	run(c, "FLUSHALL")
+}
+
+func TestBitfield(t *testing.T) {
+	c := NewClient(-1)
+	run(c, "FLUSHALL")
+
+	assert.Equal(t, "*2\r\n:1\r\n:0\r\n", run(c, "BITFIELD", "bf:a", "INCRBY", "i5", "100", "1", "GET", "u4", "0"))
+	assert.Equal(t, "*1\r\n:0\r\n", run(c, "BITFIELD", "bf:b", "SET", "i8", "#1", "-100"))
+	assert.Equal(t, "*2\r\n:-100\r\n:156\r\n", run(c, "BITFIELD", "bf:b", "GET", "i8", "8", "GET", "u8", "#1"))
+	assert.Equal(t, "$2\r\n\x00\x9c\r\n", run(c, "GET", "bf:b"))
+
+	// the overflow examples of the Redis documentation
+	assert.Equal(t, "*2\r\n:1\r\n:1\r\n", run(c, "BITFIELD", "bf:c", "INCRBY", "u2", "100", "1", "OVERFLOW", "SAT", "INCRBY", "u2", "102", "1"))
+	assert.Equal(t, "*2\r\n:2\r\n:2\r\n", run(c, "BITFIELD", "bf:c", "INCRBY", "u2", "100", "1", "OVERFLOW", "SAT", "INCRBY", "u2", "102", "1"))
+	assert.Equal(t, "*2\r\n:3\r\n:3\r\n", run(c, "BITFIELD", "bf:c", "INCRBY", "u2", "100", "1", "OVERFLOW", "SAT", "INCRBY", "u2", "102", "1"))
+	assert.Equal(t, "*2\r\n:0\r\n:3\r\n", run(c, "BITFIELD", "bf:c", "INCRBY", "u2", "100", "1", "OVERFLOW", "SAT", "INCRBY", "u2", "102", "1"))
+	assert.Equal(t, "*1\r\n$-1\r\n", run(c, "BITFIELD", "bf:c", "OVERFLOW", "FAIL", "INCRBY", "u2", "102", "1"))
+
+	assert.Equal(t, "*3\r\n:0\r\n:-128\r\n:-128\r\n", run(c, "BITFIELD", "bf:d", "SET", "i8", "0", "127",
+		"INCRBY", "i8", "0", "1", "OVERFLOW", "SAT", "INCRBY", "i8", "0", "-1000"))
+	assert.Equal(t, "*1\r\n:127\r\n", run(c, "BITFIELD", "bf:d", "OVERFLOW", "SAT", "INCRBY", "i8", "0", "1000"))
+	assert.Equal(t, "*2\r\n:0\r\n:44\r\n", run(c, "BITFIELD", "bf:f", "SET", "u8", "0", "300", "GET", "u8", "0"))
+	assert.Equal(t, "*1\r\n:9223372036854775807\r\n", run(c, "BITFIELD", "bf:g", "OVERFLOW", "SAT", "INCRBY", "i64", "0", "9223372036854775807"))
+	assert.Equal(t, "*1\r\n:9223372036854775807\r\n", run(c, "BITFIELD", "bf:g", "OVERFLOW", "SAT", "INCRBY", "i64", "0", "1"))
+	assert.Equal(t, "*1\r\n:-9223372036854775808\r\n", run(c, "BITFIELD", "bf:g", "INCRBY", "i64", "0", "1"))
+
+	// reads never create the key
+	assert.Equal(t, "*1\r\n:0\r\n", run(c, "BITFIELD", "bf:missing", "GET", "u8", "0"))
+	assert.Equal(t, ":0\r\n", run(c, "EXISTS", "bf:missing"))
+	assert.Equal(t, "-ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.\r\n",
+		run(c, "BITFIELD", "bf:a", "GET", "u64", "0"))
+	assert.Equal(t, "-ERR Invalid OVERFLOW type specified\r\n", run(c, "BITFIELD", "bf:a", "OVERFLOW", "MAYBE"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(c, "BITFIELD", "bf:a", "SET", "u8", "0"))
+	assert.Equal(t, "-ERR bit offset is not an integer or out of range\r\n", run(c, "BITFIELD", "bf:a", "GET", "u8", "-1"))
+	run(c, "FLUSHALL")
+}
+
+func TestList(t *testing.T) {
+	defer func(fill int) { config.ListMaxListpackSize = fill }(config.ListMaxListpackSize)
+	config.ListMaxListpackSize = 4
+	c := NewClient(-1)
+	run(c, "FLUSHALL")
+
+	assert.Equal(t, ":3\r\n", run(c, "RPUSH", "l:a", "b", "c", "d"))
+	assert.Equal(t, ":4\r\n", run(c, "LPUSH", "l:a", "a"))
+	assert.Equal(t, "$8\r\nlistpack\r\n", run(c, "OBJECT", "ENCODING", "l:a"))
+	assert.Equal(t, ":5\r\n", run(c, "RPUSH", "l:a", "e"))
+	assert.Equal(t, "$9\r\nquicklist\r\n", run(c, "OBJECT", "ENCODING", "l:a"))
+	assert.Equal(t, "+list\r\n", run(c, "TYPE", "l:a"))
+	assert.Equal(t, ":5\r\n", run(c, "LLEN", "l:a"))
+	assert.Equal(t, "*5\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n$1\r\nd\r\n$1\r\ne\r\n", run(c, "LRANGE", "l:a", "0", "-1"))
+	assert.Equal(t, "*2\r\n$1\r\nd\r\n$1\r\ne\r\n", run(c, "LRANGE", "l:a", "-2", "100"))
+	assert.Equal(t, "*0\r\n", run(c, "LRANGE", "l:a", "3", "1"))
+	assert.Equal(t, "$1\r\nb\r\n", run(c, "LINDEX", "l:a", "1"))
+	assert.Equal(t, "$1\r\ne\r\n", run(c, "LINDEX", "l:a", "-1"))
+	assert.Equal(t, "$-1\r\n", run(c, "LINDEX", "l:a", "5"))
+
+	assert.Equal(t, ":0\r\n", run(c, "LPUSHX", "l:missing", "x"))
+	assert.Equal(t, ":0\r\n", run(c, "EXISTS", "l:missing"))
+	run(c, "SET", "l:str", "x")
+	assert.Equal(t, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", run(c, "RPUSH", "l:str", "x"))
+
+	assert.Equal(t, "+OK\r\n", run(c, "LSET", "l:a", "-1", "E"))
+	assert.Equal(t, "-ERR index out of range\r\n", run(c, "LSET", "l:a", "5", "x"))
+	assert.Equal(t, "-ERR no such key\r\n", run(c, "LSET", "l:missing", "0", "x"))
+	assert.Equal(t, ":6\r\n", run(c, "LINSERT", "l:a", "BEFORE", "c", "x"))
+	assert.Equal(t, ":7\r\n", run(c, "LINSERT", "l:a", "after", "E", "x"))
+	assert.Equal(t, ":-1\r\n", run(c, "LINSERT", "l:a", "AFTER", "nope", "x"))
+	assert.Equal(t, ":0\r\n", run(c, "LINSERT", "l:missing", "AFTER", "a", "x"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(c, "LINSERT", "l:a", "UNDER", "a", "x"))
+
+	// a b x c d E x
+	assert.Equal(t, ":1\r\n", run(c, "LPOS", "l:a", "b"))
+	assert.Equal(t, ":6\r\n", run(c, "LPOS", "l:a", "x", "RANK", "-1"))
+	assert.Equal(t, ":6\r\n", run(c, "LPOS", "l:a", "x", "RANK", "2"))
+	assert.Equal(t, "*2\r\n:2\r\n:6\r\n", run(c, "LPOS", "l:a", "x", "COUNT", "0"))
+	assert.Equal(t, "*1\r\n:2\r\n", run(c, "LPOS", "l:a", "x", "COUNT", "0", "MAXLEN", "4"))
+	assert.Equal(t, "$-1\r\n", run(c, "LPOS", "l:a", "nope"))
+	assert.Equal(t, "*0\r\n", run(c, "LPOS", "l:missing", "x", "COUNT", "1"))
+	assert.Equal(t, "-ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list\r\n", run(c, "LPOS", "l:a", "x", "RANK", "0"))
+	assert.Equal(t, "-ERR COUNT can't be negative\r\n", run(c, "LPOS", "l:a", "x", "COUNT", "-1"))
+	assert.Equal(t, "-ERR value is out of range, value must between -9223372036854775807 and 9223372036854775807\r\n", run(c, "LPOS", "l:a", "x", "RANK", "-9223372036854775808"))
+	assert.Equal(t, "$-1\r\n", run(c, "LPOS", "l:a", "x", "RANK", "-9223372036854775807"))
+
+	assert.Equal(t, ":2\r\n", run(c, "LREM", "l:a", "0", "x"))
+	assert.Equal(t, "+OK\r\n", run(c, "LTRIM", "l:a", "1", "-2"))
+	assert.Equal(t, "*3\r\n$1\r\nb\r\n$1\r\nc\r\n$1\r\nd\r\n", run(c, "LRANGE", "l:a", "0", "-1"))
+
+	assert.Equal(t, "$1\r\nb\r\n", run(c, "LPOP", "l:a"))
+	assert.Equal(t, "*2\r\n$1\r\nd\r\n$1\r\nc\r\n", run(c, "RPOP", "l:a", "5"))
+	// the emptied list is gone
+	assert.Equal(t, ":0\r\n", run(c, "EXISTS", "l:a"))
+	assert.Equal(t, "$-1\r\n", run(c, "LPOP", "l:a"))
+	assert.Equal(t, "*-1\r\n", run(c, "LPOP", "l:a", "1"))
+	assert.Equal(t, "-ERR value is out of range, must be positive\r\n", run(c, "LPOP", "l:a", "-1"))
+
+	run(c, "RPUSH", "l:q", "1", "2", "3")
+	assert.Equal(t, "$1\r\n3\r\n", run(c, "RPOPLPUSH", "l:q", "l:q"))
+	assert.Equal(t, "$1\r\n3\r\n", run(c, "LMOVE", "l:q", "l:r", "LEFT", "RIGHT"))
+	assert.Equal(t, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", run(c, "LMOVE", "l:q", "l:str", "LEFT", "RIGHT"))
+	assert.Equal(t, ":2\r\n", run(c, "LLEN", "l:q"))
+	assert.Equal(t, "-ERR syntax error\r\n", run(c, "LMOVE", "l:q", "l:r", "UP", "RIGHT"))
+	run(c, "LMOVE", "l:q", "l:r", "RIGHT", "LEFT")
+	run(c, "LMOVE", "l:q", "l:r", "RIGHT", "LEFT")
+	assert.Equal(t, ":0\r\n", run(c, "EXISTS", "l:q"))
+	assert.Equal(t, "*3\r\n$1\r\n1\r\n$1\r\n2\r\n$1\r\n3\r\n", run(c, "LRANGE", "l:r", "0", "-1"))
+	assert.Equal(t, "$-1\r\n", run(c, "RPOPLPUSH", "l:q", "l:r"))
+	run(c, "FLUSHALL")
+}
@@ -1,27 +1,73 @@
 package core
 
 import (
+	"redis-clone/internal/config"
 	"redis-clone/internal/constant"
 	"time"
 )
 
+// expireCurrentDB is the database the next expire cycle starts with, a cycle that runs
+// out of time resumes where it stopped.
+var expireCurrentDB int
+
+var expireStats struct {
+	// timeCapReached counts the cycles stopped by their time budget
+	timeCapReached int64
+	cycleTime      time.Duration
+}
+
+// ActiveDeleteExpiredKeys runs one active expire cycle. It deletes the keys whose TTL
+// elapsed, earliest deadline first, until none is left or the time budget of the cycle
+// is spent. Like Redis active-expire-effort, config.ActiveExpireEffort raises both the
+// keys deleted between two budget checks and the budget, from 25% of
+// constant.ActiveExpireFrequency.
 func ActiveDeleteExpiredKeys() {
-	for {
-		var expiredCount = 0
-		var sampleCountRemain = constant.ActiveExpireSampleSize
-		for key, expiredTime := range dictStore.GetexpiredDictStore() {
-			sampleCountRemain--
-			if sampleCountRemain < 0 {
-				break
-			}
-			if time.Now().UnixMilli() > int64(expiredTime) {
-				dictStore.Delete(key)
-				expiredCount++
+	effort := min(max(config.ActiveExpireEffort, 1), 10) - 1
+	keysPerLoop := constant.ActiveExpireKeysPerLoop + constant.ActiveExpireKeysPerLoop/4*effort
+	budget := constant.ActiveExpireFrequency * time.Duration(25+2*effort) / 100
+
+	start := time.Now()
+	defer func() {
+		expireStats.cycleTime += time.Since(start)
+	}()
+	for i := 0; i < len(dbs); i++ {
+		db := dbs[expireCurrentDB%len(dbs)]
+		for db.ActiveExpire(uint64(time.Now().UnixMilli()), keysPerLoop) == keysPerLoop {
+			if time.Since(start) > budget {
+				expireStats.timeCapReached++
+				return
 			}
 		}
+		expireCurrentDB = (expireCurrentDB + 1) % len(dbs)
+	}
+}
 
-		if float64(expiredCount)/float64(constant.ActiveExpireSampleSize) <= constant.ActiveExpireThreshold {
-			break
+// ActiveExpireTimeout returns how long the event loop may wait for I/O before the next
+// expire cycle is due, given when the last one ran. It is negative when no key has a TTL.
+func ActiveExpireTimeout(lastCycle time.Time) time.Duration {
+	var next uint64
+	found := false
+	for _, db := range dbs {
+		if at, ok := db.NextExpireAt(); ok && (!found || at < next) {
+			next, found = at, true
 		}
 	}
+	if !found {
+		return -1
+	}
+	due := time.UnixMilli(int64(next))
+	// cycles are at least ActiveExpireFrequency apart to bound the CPU they take
+	if earliest := lastCycle.Add(constant.ActiveExpireFrequency); due.Before(earliest) {
+		due = earliest
+	}
+	return max(time.Until(due), 0)
+}
+
+// expiredKeys returns how many keys of every database were deleted because their TTL elapsed.
+func expiredKeys() int64 {
+	var n int64
+	for _, db := range dbs {
+		n += db.ExpiredKeys()
+	}
+	return n
 }
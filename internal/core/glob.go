@@ -0,0 +1,118 @@
+package core
+
+// stringMatch reports whether s matches the glob-style pattern, with the same rules
+// as stringmatchlen in Redis:
+//
+//   - any sequence of characters, including none
+//     ?      any single character
+//     [abc]  one of the listed characters, [^abc] none of them, [a-z] a range
+//     \x     the character x literally
+func stringMatch(pattern, s string, nocase bool) bool {
+	skipLongerMatches := false
+	return matchGlob(pattern, s, nocase, &skipLongerMatches, 0)
+}
+
+func lower(b byte) byte {
+	if b >= 'A' && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+func matchGlob(pattern, s string, nocase bool, skipLongerMatches *bool, nesting int) bool {
+	// protect against pathological patterns like "a*a*a*a*...b"
+	if nesting > 1000 {
+		return false
+	}
+	p := 0
+	for p < len(pattern) && len(s) > 0 {
+		switch pattern[p] {
+		case '*':
+			for p+1 < len(pattern) && pattern[p+1] == '*' {
+				p++
+			}
+			if p+1 == len(pattern) {
+				return true
+			}
+			for len(s) > 0 {
+				if matchGlob(pattern[p+1:], s, nocase, skipLongerMatches, nesting+1) {
+					return true
+				}
+				// when the rest of the pattern failed on a shorter suffix it can't match a longer one
+				if *skipLongerMatches {
+					return false
+				}
+				s = s[1:]
+			}
+			*skipLongerMatches = true
+			return false
+		case '?':
+			s = s[1:]
+		case '[':
+			p++
+			not := p < len(pattern) && pattern[p] == '^'
+			if not {
+				p++
+			}
+			match := false
+			for p < len(pattern) {
+				if pattern[p] == '\\' && p+1 < len(pattern) {
+					p++
+					if pattern[p] == s[0] {
+						match = true
+					}
+				} else if pattern[p] == ']' {
+					break
+				} else if p+2 < len(pattern) && pattern[p+1] == '-' {
+					start, end, c := pattern[p], pattern[p+2], s[0]
+					if start > end {
+						start, end = end, start
+					}
+					if nocase {
+						start, end, c = lower(start), lower(end), lower(c)
+					}
+					p += 2
+					if c >= start && c <= end {
+						match = true
+					}
+				} else if nocase && lower(pattern[p]) == lower(s[0]) {
+					match = true
+				} else if pattern[p] == s[0] {
+					match = true
+				}
+				p++
+			}
+			if p == len(pattern) {
+				// unterminated class, the last character is treated as the closing bracket
+				p--
+			}
+			if not {
+				match = !match
+			}
+			if !match {
+				return false
+			}
+			s = s[1:]
+		case '\\':
+			if p+1 < len(pattern) {
+				p++
+			}
+			fallthrough
+		default:
+			if nocase {
+				if lower(pattern[p]) != lower(s[0]) {
+					return false
+				}
+			} else if pattern[p] != s[0] {
+				return false
+			}
+			s = s[1:]
+		}
+		p++
+	}
+	// trailing stars also match an empty remainder
+	for len(s) == 0 && p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern) && len(s) == 0
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+)
+
+// readInlineCommand decodes one inline command, the telnet friendly format where
+// arguments are separated by spaces on a single line:
+//
+//	SET "hello world" 'it\'s' \r\n
+//
+// A blank line is consumed and returned as a nil command.
+func readInlineCommand(data []byte) (*Command, int, error) {
+	end := bytes.IndexByte(data, '\n')
+	if end < 0 {
+		if len(data) > maxLineLen {
+			return nil, 0, protocolError("too big inline request")
+		}
+		return nil, 0, ErrIncomplete
+	}
+	line := data[:end]
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	tokens, ok := splitArgs(line)
+	if !ok {
+		return nil, 0, protocolError("unbalanced quotes in request")
+	}
+	if len(tokens) == 0 {
+		return nil, end + 1, nil
+	}
+	return &Command{Cmd: strings.ToUpper(tokens[0]), Args: tokens[1:]}, end + 1, nil
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\v' || b == '\f'
+}
+
+func isHexDigit(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')
+}
+
+func hexDigitToInt(b byte) byte {
+	switch {
+	case b >= '0' && b <= '9':
+		return b - '0'
+	case b >= 'a' && b <= 'f':
+		return b - 'a' + 10
+	default:
+		return b - 'A' + 10
+	}
+}
+
+// splitArgs splits a line into arguments following the rules of sdssplitargs in Redis:
+// double quoted arguments support \n \r \t \b \a \\ \" and \xHH escapes, single quoted
+// arguments only support \', and a closing quote must be followed by a space or the end
+// of the line. It returns false when the quotes are unbalanced.
+func splitArgs(line []byte) ([]string, bool) {
+	var args []string
+	p := 0
+	for {
+		for p < len(line) && isSpace(line[p]) {
+			p++
+		}
+		if p == len(line) {
+			return args, true
+		}
+		var current []byte
+		inDoubleQuotes, inSingleQuotes := false, false
+		done := false
+		for !done {
+			switch {
+			case inDoubleQuotes:
+				if p == len(line) {
+					return nil, false
+				}
+				if line[p] == '\\' && p+3 < len(line) && line[p+1] == 'x' && isHexDigit(line[p+2]) && isHexDigit(line[p+3]) {
+					current = append(current, hexDigitToInt(line[p+2])*16+hexDigitToInt(line[p+3]))
+					p += 3
+				} else if line[p] == '\\' && p+1 < len(line) {
+					p++
+					switch line[p] {
+					case 'n':
+						current = append(current, '\n')
+					case 'r':
+						current = append(current, '\r')
+					case 't':
+						current = append(current, '\t')
+					case 'b':
+						current = append(current, '\b')
+					case 'a':
+						current = append(current, '\a')
+					default:
+						current = append(current, line[p])
+					}
+				} else if line[p] == '"' {
+					// closing quote must be followed by a space or nothing at all
+					if p+1 < len(line) && !isSpace(line[p+1]) {
+						return nil, false
+					}
+					done = true
+				} else {
+					current = append(current, line[p])
+				}
+			case inSingleQuotes:
+				if p == len(line) {
+					return nil, false
+				}
+				if line[p] == '\\' && p+1 < len(line) && line[p+1] == '\'' {
+					p++
+					current = append(current, '\'')
+				} else if line[p] == '\'' {
+					if p+1 < len(line) && !isSpace(line[p+1]) {
+						return nil, false
+					}
+					done = true
+				} else {
+					current = append(current, line[p])
+				}
+			default:
+				if p == len(line) {
+					done = true
+					break
+				}
+				switch line[p] {
+				case ' ', '\n', '\r', '\t', '\v', '\f':
+					done = true
+				case '"':
+					inDoubleQuotes = true
+				case '\'':
+					inSingleQuotes = true
+				default:
+					current = append(current, line[p])
+				}
+			}
+			if p < len(line) {
+				p++
+			}
+		}
+		args = append(args, string(current))
+	}
+}
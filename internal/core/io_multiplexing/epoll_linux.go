@@ -6,6 +6,7 @@ import (
 	"log"
 	"redis-clone/internal/config"
 	"syscall"
+	"time"
 )
 
 type Epoll struct {
@@ -34,8 +35,23 @@ func (ep *Epoll) Monitor(event Event) error {
 	return syscall.EpollCtl(ep.fd, syscall.EPOLL_CTL_ADD, event.Fd, &epollEvent)
 }
 
-func (ep *Epoll) Wait() ([]Event, error) {
-	n, err := syscall.EpollWait(ep.fd, ep.epollEvents, -1)
+func (ep *Epoll) Modify(event Event) error {
+	epollEvent := event.toNative()
+	return syscall.EpollCtl(ep.fd, syscall.EPOLL_CTL_MOD, event.Fd, &epollEvent)
+}
+
+func (ep *Epoll) Remove(fd int) error {
+	// a non-nil event is still required by kernels older than 2.6.9
+	return syscall.EpollCtl(ep.fd, syscall.EPOLL_CTL_DEL, fd, &syscall.EpollEvent{})
+}
+
+func (ep *Epoll) Wait(timeout time.Duration) ([]Event, error) {
+	msec := -1
+	if timeout >= 0 {
+		// round up, waking up early would only spin the event loop
+		msec = int((timeout + time.Millisecond - 1) / time.Millisecond)
+	}
+	n, err := syscall.EpollWait(ep.fd, ep.epollEvents, msec)
 	if err != nil {
 		return nil, err
 	}
@@ -1,5 +1,7 @@
 package io_multiplexing
 
+import "time"
+
 const OpRead = 0
 const OpWrite = 1
 
@@ -11,7 +13,13 @@ type Event struct {
 }
 
 type IOMultiplexer interface {
+	// Monitor starts watching event.Fd for event.Op
 	Monitor(event Event) error
-	Wait() ([]Event, error)
+	// Modify switches an already monitored event.Fd to watch for event.Op instead
+	Modify(event Event) error
+	// Remove stops watching fd
+	Remove(fd int) error
+	// Wait blocks until some fds are ready or timeout elapses, a negative timeout blocks forever
+	Wait(timeout time.Duration) ([]Event, error)
 	Close() error
 }
@@ -6,6 +6,7 @@ import (
 	"log"
 	"redis-clone/internal/config"
 	"syscall"
+	"time"
 )
 
 type KQueue struct {
@@ -35,8 +36,35 @@ func (kq *KQueue) Monitor(event Event) error {
 	return err
 }
 
-func (kq *KQueue) Wait() ([]Event, error) {
-	n, err := syscall.Kevent(kq.fd, nil, kq.kqEvents, nil)
+func (kq *KQueue) Modify(event Event) error {
+	// kqueue keeps one filter per operation, so drop the other one before adding the new one
+	other := Event{Fd: event.Fd, Op: OpWrite}
+	if event.Op == OpWrite {
+		other.Op = OpRead
+	}
+	_, _ = syscall.Kevent(kq.fd, []syscall.Kevent_t{other.toNative(syscall.EV_DELETE)}, nil, nil)
+	return kq.Monitor(event)
+}
+
+func (kq *KQueue) Remove(fd int) error {
+	changes := []syscall.Kevent_t{
+		Event{Fd: fd, Op: OpRead}.toNative(syscall.EV_DELETE),
+		Event{Fd: fd, Op: OpWrite}.toNative(syscall.EV_DELETE),
+	}
+	// deleting a filter that was never added fails with ENOENT, which is fine here
+	for _, change := range changes {
+		_, _ = syscall.Kevent(kq.fd, []syscall.Kevent_t{change}, nil, nil)
+	}
+	return nil
+}
+
+func (kq *KQueue) Wait(timeout time.Duration) ([]Event, error) {
+	var ts *syscall.Timespec
+	if timeout >= 0 {
+		t := syscall.NsecToTimespec(int64(timeout))
+		ts = &t
+	}
+	n, err := syscall.Kevent(kq.fd, nil, kq.kqEvents, ts)
 	if err != nil {
 		return nil, err
 	}
@@ -17,7 +17,8 @@ func (e Event) toNative() syscall.EpollEvent {
 
 func createEvent(ep syscall.EpollEvent) Event {
 	var op Operation = OpRead
-	if ep.Events == syscall.EPOLLOUT {
+	// error and hang-up events are reported as reads so the following read sees the failure
+	if ep.Events&syscall.EPOLLOUT != 0 && ep.Events&syscall.EPOLLIN == 0 {
 		op = OpWrite
 	}
 	return Event{
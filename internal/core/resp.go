@@ -4,40 +4,111 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"math"
+	"redis-clone/internal/config"
+	"strconv"
 	"strings"
 )
 
 const CRLF string = "\r\n"
 
+// maxLineLen bounds the header line of a frame (type byte, length and CRLF),
+// like PROTO_INLINE_MAX_SIZE in Redis.
+const maxLineLen = 64 * 1024
+
 var RespNil = []byte("$-1\r\n")
 
+// ErrIncomplete is returned when data ends before a whole RESP frame has been received.
+var ErrIncomplete = errors.New("incomplete RESP frame")
+
+// ErrProtocol and ErrOversized are the kinds of ProtocolError, use errors.Is to tell them apart.
+var ErrProtocol = errors.New("malformed RESP frame")
+var ErrOversized = errors.New("RESP length over proto-max-bulk-len")
+
+// ProtocolError reports input that can never become a valid frame. The server
+// answers it and closes the connection since the stream can't be resynchronised.
+type ProtocolError struct {
+	Kind   error
+	Reason string
+}
+
+func (e *ProtocolError) Error() string {
+	return "ERR Protocol error: " + e.Reason
+}
+
+func (e *ProtocolError) Unwrap() error {
+	return e.Kind
+}
+
+func protocolError(format string, args ...interface{}) error {
+	return &ProtocolError{Kind: ErrProtocol, Reason: fmt.Sprintf(format, args...)}
+}
+
+// readLine returns the index of the CRLF that ends the first line of data.
+func readLine(data []byte) (int, error) {
+	end := bytes.Index(data, []byte(CRLF))
+	if end < 0 {
+		if len(data) > maxLineLen {
+			return 0, protocolError("too big line")
+		}
+		return 0, ErrIncomplete
+	}
+	return end, nil
+}
+
 // +OK\r\n => OK, 5
 func readSimpleString(data []byte) (string, int, error) {
-	pos := 1
-	for data[pos] != '\r' {
-		pos++
+	end, err := readLine(data)
+	if err != nil {
+		return "", 0, err
 	}
-	return string(data[1:pos]), pos + 2, nil
+	return string(data[1:end]), end + 2, nil
 }
 
-// :123\r\n => 123
-func readInt64(data []byte) (int64, int, error) {
+// parseInt64 parses an optionally signed decimal number, rejecting empty input,
+// stray characters and values that overflow int64.
+func parseInt64(b []byte) (int64, bool) {
 	var res int64 = 0
-	pos := 1
-	var sign int64 = 1
-	if data[pos] == '-' {
-		sign = -1
+	pos := 0
+	negative := false
+	if pos < len(b) && (b[pos] == '-' || b[pos] == '+') {
+		negative = b[pos] == '-'
 		pos++
 	}
-	if data[pos] == '+' {
-		pos++
+	if pos == len(b) {
+		return 0, false
 	}
-	for data[pos] != '\r' {
-		res = res*10 + int64(data[pos]-'0')
-		pos++
+	for ; pos < len(b); pos++ {
+		if b[pos] < '0' || b[pos] > '9' {
+			return 0, false
+		}
+		digit := int64(b[pos] - '0')
+		// accumulate as a negative number so math.MinInt64 can be represented
+		if res < (-1<<63+digit)/10 {
+			return 0, false
+		}
+		res = res*10 - digit
 	}
+	if !negative {
+		if res == -1<<63 {
+			return 0, false
+		}
+		res = -res
+	}
+	return res, true
+}
 
-	return sign * res, pos + 2, nil
+// :123\r\n => 123
+func readInt64(data []byte) (int64, int, error) {
+	end, err := readLine(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	res, ok := parseInt64(data[1:end])
+	if !ok {
+		return 0, 0, protocolError("invalid integer")
+	}
+	return res, end + 2, nil
 }
 
 func readError(data []byte) (string, int, error) {
@@ -45,28 +116,58 @@ func readError(data []byte) (string, int, error) {
 }
 
 // $5\r\nhello\r\n => 5, 4
-func readLen(data []byte) (int, int) {
-	res, pos, _ := readInt64(data)
-	return int(res), pos
+// A length of -1 is the null value, any other negative or too large length is rejected.
+func readLen(data []byte, what string) (int, int, error) {
+	end, err := readLine(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	res, ok := parseInt64(data[1:end])
+	if !ok || res < -1 {
+		return 0, 0, protocolError("invalid %s length", what)
+	}
+	if res > config.ProtoMaxBulkLen {
+		return 0, 0, &ProtocolError{Kind: ErrOversized, Reason: fmt.Sprintf("invalid %s length", what)}
+	}
+	return int(res), end + 2, nil
 }
 
 // $5\r\nhello\r\n => "hello"
-func readBulkString(data []byte) (string, int, error) {
-	length, pos := readLen(data)
+func readBulkString(data []byte) (interface{}, int, error) {
+	length, pos, err := readLen(data, "bulk")
+	if err != nil {
+		return nil, 0, err
+	}
+	if length < 0 {
+		return nil, pos, nil
+	}
+	if len(data) < pos+length+2 {
+		return nil, 0, ErrIncomplete
+	}
+	if data[pos+length] != '\r' || data[pos+length+1] != '\n' {
+		return nil, 0, protocolError("bulk string is not terminated by CRLF")
+	}
 	return string(data[pos:(pos + length)]), pos + length + 2, nil
 }
 
 // *2\r\n$5\r\nhello\r\n$5\r\nworld\r\n => {"hello", "world"}
 func readArray(data []byte) (interface{}, int, error) {
-	length, pos := readLen(data)
-	var res []interface{} = make([]interface{}, length)
+	length, pos, err := readLen(data, "multibulk")
+	if err != nil {
+		return nil, 0, err
+	}
+	if length < 0 {
+		return nil, pos, nil
+	}
+	// every element takes at least 3 bytes, so don't trust the header for the allocation size
+	var res []interface{} = make([]interface{}, 0, min(length, len(data)/3+1))
 
-	for i := range res {
+	for i := 0; i < length; i++ {
 		elem, delta, err := DecodeOne(data[pos:])
 		if err != nil {
 			return nil, 0, err
 		}
-		res[i] = elem
+		res = append(res, elem)
 		pos += delta
 	}
 	return res, pos, nil
@@ -74,7 +175,7 @@ func readArray(data []byte) (interface{}, int, error) {
 
 func DecodeOne(data []byte) (interface{}, int, error) {
 	if len(data) == 0 {
-		return nil, 0, errors.New("no data")
+		return nil, 0, ErrIncomplete
 	}
 	switch data[0] {
 	case '+':
@@ -88,7 +189,7 @@ func DecodeOne(data []byte) (interface{}, int, error) {
 	case '*':
 		return readArray(data)
 	}
-	return nil, 0, nil
+	return nil, 0, protocolError("unexpected type byte '%c'", data[0])
 }
 
 // RESP format data => raw data
@@ -97,6 +198,42 @@ func Decode(data []byte) (interface{}, error) {
 	return res, err
 }
 
+// Protocol versions a connection can speak, switched with HELLO.
+const (
+	Resp2 = 2
+	Resp3 = 3
+)
+
+// RESP3 reply types. Encoded for a RESP2 connection each of them degrades to the
+// closest RESP2 type, the same way Redis downgrades its replies.
+type (
+	// RespMap holds alternating keys and values, a flat array in RESP2.
+	RespMap []interface{}
+	// RespSet is an unordered collection, an array in RESP2.
+	RespSet []interface{}
+	// RespDouble is a bulk string in RESP2.
+	RespDouble float64
+	// RespBool is the integer 1 or 0 in RESP2.
+	RespBool bool
+	// RespBigNumber holds the decimal digits of an integer too large for int64, a bulk string in RESP2.
+	RespBigNumber string
+	// RespPush is an out of band message such as an invalidation, an array in RESP2.
+	RespPush []interface{}
+	// RespNull is the null bulk string in RESP2.
+	RespNull struct{}
+	// RespNullArray is the null array in RESP2, the same null as RespNull in RESP3.
+	RespNullArray struct{}
+	// RespSimpleString is a status reply nested inside an aggregate.
+	RespSimpleString string
+)
+
+// RespVerbatim is a string with a three letter format hint such as "txt" or "mkd",
+// a plain bulk string in RESP2.
+type RespVerbatim struct {
+	Format string
+	Text   string
+}
+
 func encodeString(s string) []byte {
 	return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(s), s))
 }
@@ -110,50 +247,189 @@ func encodeStringArray(sa []string) []byte {
 	return []byte(fmt.Sprintf("*%d\r\n%s", len(sa), buf.Bytes()))
 }
 
+func encodeAggregate(prefix byte, items []interface{}, proto int) []byte {
+	var b []byte
+	buf := bytes.NewBuffer(b)
+	buf.WriteString(fmt.Sprintf("%c%d\r\n", prefix, len(items)))
+	for _, x := range items {
+		buf.Write(EncodeProto(x, proto))
+	}
+	return buf.Bytes()
+}
+
+// FormatDouble renders a float the way Redis replies with scores: the shortest
+// representation that parses back to the same value, and inf, -inf or nan.
+func FormatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
 // raw data => RESP format data
 func Encode(value interface{}, isSimpleString bool) []byte {
+	if v, ok := value.(string); ok && isSimpleString {
+		return []byte(fmt.Sprintf("+%s%s", v, CRLF))
+	}
+	return EncodeProto(value, Resp2)
+}
+
+// EncodeProto encodes value for a connection speaking the given protocol version.
+func EncodeProto(value interface{}, proto int) []byte {
 	switch v := value.(type) {
 	case string:
-		if isSimpleString {
-			return []byte(fmt.Sprintf("+%s%s", v, CRLF))
-		}
 		return []byte(fmt.Sprintf("$%d%s%s%s", len(v), CRLF, v, CRLF))
+	case RespSimpleString:
+		return []byte(fmt.Sprintf("+%s%s", v, CRLF))
 	case int64, int32, int16, int8, int:
 		return []byte(fmt.Sprintf(":%d\r\n", v))
 	case error:
 		return []byte(fmt.Sprintf("-%s\r\n", v))
 	case []string:
-		return encodeStringArray(value.([]string))
+		return encodeStringArray(v)
 	case [][]string:
 		var b []byte
 		buf := bytes.NewBuffer(b)
-		for _, sa := range value.([][]string) {
+		for _, sa := range v {
 			buf.Write(encodeStringArray(sa))
 		}
-		return []byte(fmt.Sprintf("*%d\r\n%s", len(value.([][]string)), buf.Bytes()))
+		return []byte(fmt.Sprintf("*%d\r\n%s", len(v), buf.Bytes()))
 	case []interface{}:
+		return encodeAggregate('*', v, proto)
+	case RespMap:
+		if proto == Resp2 {
+			return encodeAggregate('*', v, proto)
+		}
 		var b []byte
 		buf := bytes.NewBuffer(b)
-		for _, x := range value.([]interface{}) {
-			buf.Write(Encode(x, false))
+		buf.WriteString(fmt.Sprintf("%%%d\r\n", len(v)/2))
+		for _, x := range v {
+			buf.Write(EncodeProto(x, proto))
+		}
+		return buf.Bytes()
+	case RespSet:
+		if proto == Resp2 {
+			return encodeAggregate('*', v, proto)
+		}
+		return encodeAggregate('~', v, proto)
+	case RespPush:
+		if proto == Resp2 {
+			return encodeAggregate('*', v, proto)
+		}
+		return encodeAggregate('>', v, proto)
+	case RespDouble:
+		if proto == Resp2 {
+			return EncodeProto(FormatDouble(float64(v)), proto)
 		}
-		return []byte(fmt.Sprintf("*%d\r\n%s", len(value.([]interface{})), buf.Bytes()))
+		return []byte(fmt.Sprintf(",%s\r\n", FormatDouble(float64(v))))
+	case RespBool:
+		if proto == Resp2 {
+			if v {
+				return EncodeProto(1, proto)
+			}
+			return EncodeProto(0, proto)
+		}
+		if v {
+			return []byte("#t\r\n")
+		}
+		return []byte("#f\r\n")
+	case RespBigNumber:
+		if proto == Resp2 {
+			return EncodeProto(string(v), proto)
+		}
+		return []byte(fmt.Sprintf("(%s\r\n", v))
+	case RespVerbatim:
+		if proto == Resp2 {
+			return EncodeProto(v.Text, proto)
+		}
+		return []byte(fmt.Sprintf("=%d\r\n%s:%s\r\n", len(v.Format)+1+len(v.Text), v.Format, v.Text))
+	case RespNullArray:
+		if proto == Resp3 {
+			return []byte("_\r\n")
+		}
+		return []byte("*-1\r\n")
 	default:
+		// nil and RespNull
+		if proto == Resp3 {
+			return []byte("_\r\n")
+		}
 		return RespNil
 	}
 }
 
+// readCommand decodes one command from the head of data: an array of bulk strings,
+// or an inline command when the first byte is not '*'.
+// An empty array or blank line is consumed and returned as a nil command, like Redis does.
+func readCommand(data []byte) (*Command, int, error) {
+	if len(data) == 0 {
+		return nil, 0, ErrIncomplete
+	}
+	if data[0] != '*' {
+		return readInlineCommand(data)
+	}
+	length, pos, err := readLen(data, "multibulk")
+	if err != nil {
+		return nil, 0, err
+	}
+	if length <= 0 {
+		return nil, pos, nil
+	}
+	tokens := make([]string, 0, min(length, len(data)/4+1))
+	for i := 0; i < length; i++ {
+		if pos >= len(data) {
+			return nil, 0, ErrIncomplete
+		}
+		if data[pos] != '$' {
+			return nil, 0, protocolError("expected '$', got '%c'", data[pos])
+		}
+		token, delta, err := readBulkString(data[pos:])
+		if err != nil {
+			return nil, 0, err
+		}
+		if token == nil {
+			return nil, 0, protocolError("invalid bulk length")
+		}
+		tokens = append(tokens, token.(string))
+		pos += delta
+	}
+	return &Command{Cmd: strings.ToUpper(tokens[0]), Args: tokens[1:]}, pos, nil
+}
+
 func ParseCmd(data []byte) (*Command, error) {
-	value, err := Decode(data)
+	cmd, _, err := readCommand(data)
 	if err != nil {
 		return nil, err
 	}
+	if cmd == nil {
+		return nil, protocolError("empty command")
+	}
+	return cmd, nil
+}
 
-	array := value.([]interface{})
-	tokens := make([]string, len(array))
-	for i := range tokens {
-		tokens[i] = array[i].(string)
+// ParseCmds parses every complete command at the head of data, in order, and
+// returns them with the number of bytes consumed. A trailing partial frame is
+// left unconsumed so the caller can retry once more bytes have arrived.
+// On a *ProtocolError the commands before the bad frame are still returned.
+func ParseCmds(data []byte) ([]*Command, int, error) {
+	var cmds []*Command
+	pos := 0
+	for pos < len(data) {
+		cmd, delta, err := readCommand(data[pos:])
+		if err == ErrIncomplete {
+			break
+		}
+		if err != nil {
+			return cmds, pos, err
+		}
+		if cmd != nil {
+			cmds = append(cmds, cmd)
+		}
+		pos += delta
 	}
-	res := &Command{Cmd: strings.ToUpper(tokens[0]), Args: tokens[1:]}
-	return res, nil
+	return cmds, pos, nil
 }
@@ -0,0 +1,84 @@
+package core_test
+
+import (
+	"errors"
+	"redis-clone/internal/core"
+	"testing"
+)
+
+var fuzzSeeds = []string{
+	"*1\r\n$4\r\nPING\r\n",
+	"*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n*2\r\n$3\r\nGET\r\n$1\r\nk\r\n",
+	"*2\r\n$3\r\nGET\r\n$1",
+	"*2\r\n*3\r\n:1\r\n:2\r\n:3\r\n*2\r\n+Hello\r\n-World\r\n",
+	"$-1\r\n",
+	"*-1\r\n",
+	"*0\r\n",
+	"$5\r\nhel",
+	"*1\r\n$-5\r\n",
+	"*1\r\n$99999999999999999999\r\n",
+	":-9223372036854775808\r\n",
+	"*1\r\n:1\r\n",
+	"$3\r\nabcXY",
+	"+OK",
+	"?\r\n",
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, n, err := core.DecodeOne(data)
+		if err != nil {
+			var protoErr *core.ProtocolError
+			if !errors.Is(err, core.ErrIncomplete) && !errors.As(err, &protoErr) {
+				t.Fatalf("unexpected error type %T: %v", err, err)
+			}
+			return
+		}
+		if n <= 0 || n > len(data) {
+			t.Fatalf("consumed %d bytes of %d", n, len(data))
+		}
+	})
+}
+
+func FuzzParseCmds(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cmds, n, err := core.ParseCmds(data)
+		if n < 0 || n > len(data) {
+			t.Fatalf("consumed %d bytes of %d", n, len(data))
+		}
+		if err != nil {
+			var protoErr *core.ProtocolError
+			if !errors.As(err, &protoErr) {
+				t.Fatalf("unexpected error type %T: %v", err, err)
+			}
+		}
+		for _, cmd := range cmds {
+			if cmd == nil {
+				t.Fatal("nil command returned")
+			}
+		}
+		// feeding the same bytes one at a time must give the same commands
+		if err == nil {
+			var total []*core.Command
+			var buf []byte
+			for i := range data {
+				buf = append(buf, data[i])
+				part, consumed, err := core.ParseCmds(buf)
+				if err != nil {
+					t.Fatalf("byte-by-byte parse failed: %v", err)
+				}
+				total = append(total, part...)
+				buf = buf[consumed:]
+			}
+			if len(total) != len(cmds) {
+				t.Fatalf("got %d commands byte by byte, %d at once", len(total), len(cmds))
+			}
+		}
+	})
+}
@@ -3,6 +3,7 @@ package core_test
 import (
 	"fmt"
 	"redis-clone/internal/core"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -115,3 +116,98 @@ func TestParseCmd(t *testing.T) {
 		}
 	}
 }
+
+func TestParseCmdsPipelineAndPartial(t *testing.T) {
+	data := []byte("*1\r\n$4\r\nPING\r\n*3\r\n$3\r\nset\r\n$1\r\nk\r\n$1\r\nv\r\n*2\r\n$3\r\nGET\r\n$1")
+	cmds, consumed, err := core.ParseCmds(data)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(cmds))
+	assert.Equal(t, "PING", cmds[0].Cmd)
+	assert.Equal(t, "SET", cmds[1].Cmd)
+	assert.Equal(t, []string{"k", "v"}, cmds[1].Args)
+
+	rest := append(data[consumed:], []byte("\r\nk\r\n")...)
+	cmds, consumed, err = core.ParseCmds(rest)
+	assert.Nil(t, err)
+	assert.Equal(t, len(rest), consumed)
+	assert.Equal(t, 1, len(cmds))
+	assert.Equal(t, "GET", cmds[0].Cmd)
+	assert.Equal(t, []string{"k"}, cmds[0].Args)
+}
+
+func TestParseCmdsLargeBulk(t *testing.T) {
+	value := strings.Repeat("x", 4096)
+	data := []byte(fmt.Sprintf("*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$%d\r\n%s\r\n", len(value), value))
+	cmds, consumed, err := core.ParseCmds(data[:1000])
+	assert.Nil(t, err)
+	assert.Equal(t, 0, consumed)
+	assert.Equal(t, 0, len(cmds))
+
+	cmds, consumed, err = core.ParseCmds(data)
+	assert.Nil(t, err)
+	assert.Equal(t, len(data), consumed)
+	assert.Equal(t, value, cmds[0].Args[1])
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	incomplete := []string{"", "+OK", ":12", "$5\r\nhel", "*2\r\n:1\r\n", "*2\r\n$3\r\nGET\r\n$1"}
+	for _, c := range incomplete {
+		_, _, err := core.DecodeOne([]byte(c))
+		assert.ErrorIs(t, err, core.ErrIncomplete, "%q", c)
+	}
+	malformed := []string{"?\r\n", ":1a\r\n", ":\r\n", "$-2\r\n", "$3\r\nabcXY", "*1x\r\n", ":9223372036854775808\r\n"}
+	for _, c := range malformed {
+		_, _, err := core.DecodeOne([]byte(c))
+		assert.ErrorIs(t, err, core.ErrProtocol, "%q", c)
+	}
+	_, _, err := core.DecodeOne([]byte("$99999999999\r\n"))
+	assert.ErrorIs(t, err, core.ErrOversized)
+}
+
+func TestParseCmdsProtocolError(t *testing.T) {
+	cmds, consumed, err := core.ParseCmds([]byte("*1\r\n$4\r\nPING\r\n*1\r\n:1\r\n*1\r\n$4\r\nPING\r\n"))
+	assert.Equal(t, 1, len(cmds))
+	assert.Equal(t, 14, consumed)
+	var protoErr *core.ProtocolError
+	assert.ErrorAs(t, err, &protoErr)
+	assert.Equal(t, "ERR Protocol error: expected '$', got ':'", err.Error())
+}
+
+func TestParseInlineCmds(t *testing.T) {
+	data := []byte("ping\r\n\r\nSET  k \"hello\\x41 \\\"w\\\"\\n\" 'it\\'s'\nGET k\r\nEXIS")
+	cmds, consumed, err := core.ParseCmds(data)
+	assert.Nil(t, err)
+	assert.Equal(t, len(data)-4, consumed)
+	assert.Equal(t, 3, len(cmds))
+	assert.Equal(t, "PING", cmds[0].Cmd)
+	assert.Equal(t, 0, len(cmds[0].Args))
+	assert.Equal(t, "SET", cmds[1].Cmd)
+	assert.Equal(t, []string{"k", "helloA \"w\"\n", "it's"}, cmds[1].Args)
+	assert.Equal(t, []string{"k"}, cmds[2].Args)
+
+	for _, c := range []string{"SET k \"v\n", "SET k 'v'x\n", "SET k \"v\"x\n"} {
+		_, _, err = core.ParseCmds([]byte(c))
+		assert.EqualError(t, err, "ERR Protocol error: unbalanced quotes in request", c)
+	}
+}
+
+func TestEncodeResp3(t *testing.T) {
+	cases := []struct {
+		value interface{}
+		resp2 string
+		resp3 string
+	}{
+		{core.RespMap{"a", 1}, "*2\r\n$1\r\na\r\n:1\r\n", "%1\r\n$1\r\na\r\n:1\r\n"},
+		{core.RespSet{"x"}, "*1\r\n$1\r\nx\r\n", "~1\r\n$1\r\nx\r\n"},
+		{core.RespDouble(1.5), "$3\r\n1.5\r\n", ",1.5\r\n"},
+		{core.RespBool(true), ":1\r\n", "#t\r\n"},
+		{nil, "$-1\r\n", "_\r\n"},
+		{core.RespBigNumber("3492890328409238509324850943850943825024385"), "$43\r\n3492890328409238509324850943850943825024385\r\n", "(3492890328409238509324850943850943825024385\r\n"},
+		{core.RespVerbatim{Format: "txt", Text: "Some string"}, "$11\r\nSome string\r\n", "=15\r\ntxt:Some string\r\n"},
+		{core.RespPush{"invalidate", []interface{}{"k"}}, "*2\r\n$10\r\ninvalidate\r\n*1\r\n$1\r\nk\r\n", ">2\r\n$10\r\ninvalidate\r\n*1\r\n$1\r\nk\r\n"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.resp2, string(core.EncodeProto(c.value, core.Resp2)))
+		assert.Equal(t, c.resp3, string(core.EncodeProto(c.value, core.Resp3)))
+	}
+}
@@ -1,18 +1,46 @@
 package core
 
-import "redis-clone/internal/data_structure"
+import (
+	"errors"
+	"redis-clone/internal/config"
+	"redis-clone/internal/data_structure"
+	"strconv"
+)
 
-var dictStore *data_structure.Dict
-var setStore map[string]*data_structure.SimpleSet
-var zsetStore map[string]*data_structure.SortedSet
-var cmsStore map[string]*data_structure.CMS
-var bloomStore map[string]*data_structure.Bloom
+// dbs are the logical databases, each one a keyspace holding objects of every type
+var dbs []*data_structure.Dict
+
+var errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+var errDBIndexOutOfRange = errors.New("ERR DB index is out of range")
 
 func init() {
-	dictStore = data_structure.CreateDict()
-	setStore = make(map[string]*data_structure.SimpleSet)
-	zsetStore = make(map[string]*data_structure.SortedSet)
-	cmsStore = make(map[string]*data_structure.CMS)
-	bloomStore = make(map[string]*data_structure.Bloom)
+	dbs = make([]*data_structure.Dict, config.Databases)
+	for i := range dbs {
+		dbs[i] = data_structure.CreateDict()
+	}
+}
+
+// lookupKey returns the live object stored at key in db, or nil when the key does not
+// exist. An object of another type than objType is reported with errWrongType.
+func lookupKey(db *data_structure.Dict, key string, objType uint8) (*data_structure.Obj, error) {
+	obj := db.Get(key)
+	if obj == nil {
+		return nil, nil
+	}
+	if obj.Type != objType {
+		return nil, errWrongType
+	}
+	return obj, nil
+}
 
+// parseDBIndex parses a database index argument of SELECT, MOVE, SWAPDB and COPY.
+func parseDBIndex(arg string) (int, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, errNotInteger
+	}
+	if id < 0 || id >= len(dbs) {
+		return 0, errDBIndexOutOfRange
+	}
+	return id, nil
 }
@@ -0,0 +1,54 @@
+package data_structure
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Bitmaps are plain string values, bit 0 is the most significant bit of the first byte
+// like in Redis. The helpers below work a 64-bit word at a time where they can.
+
+// Popcount returns the number of bits set in b.
+func Popcount(b []byte) int {
+	count := 0
+	for len(b) >= 8 {
+		count += bits.OnesCount64(binary.BigEndian.Uint64(b))
+		b = b[8:]
+	}
+	for _, x := range b {
+		count += bits.OnesCount8(x)
+	}
+	return count
+}
+
+// BitPos returns the position of the first bit of b equal to bit, or -1 when there is
+// none.
+func BitPos(b []byte, bit int) int {
+	// words with no matching bit are skipped as a whole
+	var skip uint64
+	if bit == 0 {
+		skip = ^uint64(0)
+	}
+	pos := 0
+	for len(b) >= 8 {
+		w := binary.BigEndian.Uint64(b)
+		if w != skip {
+			if bit == 0 {
+				w = ^w
+			}
+			return pos + bits.LeadingZeros64(w)
+		}
+		b = b[8:]
+		pos += 64
+	}
+	for _, x := range b {
+		if bit == 0 {
+			x = ^x
+		}
+		if x != 0 {
+			return pos + bits.LeadingZeros8(x)
+		}
+		pos += 8
+	}
+	return -1
+}
@@ -0,0 +1,56 @@
+package data_structure_test
+
+import (
+	"math/bits"
+	"math/rand"
+	"redis-clone/internal/data_structure"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPopcountAndBitPos(t *testing.T) {
+	for n := 0; n < 40; n++ {
+		b := make([]byte, n)
+		rand.Read(b)
+		count := 0
+		for _, x := range b {
+			count += bits.OnesCount8(x)
+		}
+		assert.Equal(t, count, data_structure.Popcount(b))
+	}
+
+	b := make([]byte, 20)
+	assert.Equal(t, -1, data_structure.BitPos(b, 1))
+	assert.Equal(t, 0, data_structure.BitPos(b, 0))
+	b[17] = 0x10
+	assert.Equal(t, 17*8+3, data_structure.BitPos(b, 1))
+	for i := range b {
+		b[i] = 0xff
+	}
+	assert.Equal(t, -1, data_structure.BitPos(b, 0))
+	b[9] = 0xfe
+	assert.Equal(t, 9*8+7, data_structure.BitPos(b, 0))
+}
+
+func TestMutableString(t *testing.T) {
+	assert.Equal(t, []byte{'a', 'b', 0, 0}, data_structure.MutableString("ab", 4))
+	assert.Equal(t, []byte("12"), data_structure.MutableString(int64(12), 1))
+
+	// a buffer is changed in place, growing it one byte at a time reallocates rarely
+	var buf []byte
+	reallocs := 0
+	for size := 1; size <= 1<<16; size++ {
+		grown := data_structure.MutableString(buf, size)
+		if cap(grown) != cap(buf) {
+			reallocs++
+		}
+		grown[size-1] = 1
+		buf = grown
+	}
+	assert.Less(t, reallocs, 40)
+	assert.Equal(t, 1<<16, data_structure.Popcount(buf))
+	data_structure.MutableString(buf, 10)[0] = 0xff
+	assert.Equal(t, byte(0xff), buf[0])
+	assert.Equal(t, "\xff\x01", data_structure.StringValue(buf[:2]))
+}
@@ -107,3 +107,16 @@ func (b *Bloom) ExistHash(initHash HashValue) bool {
 	}
 	return true
 }
+
+// Copy returns a filter with the same parameters and bits.
+func (b *Bloom) Copy() *Bloom {
+	res := *b
+	res.bf = make([]uint8, len(b.bf))
+	copy(res.bf, b.bf)
+	return &res
+}
+
+// MemoryUsage returns the approximate bytes held by the filter.
+func (b *Bloom) MemoryUsage() int64 {
+	return 64 + sliceHeaderSize + int64(b.bytes)
+}
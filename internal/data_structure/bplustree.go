@@ -243,3 +243,17 @@ func (t *BPlusTree) GetRank(member string) int {
 
 	return -1 // Member not found
 }
+
+// Items returns every item in ascending order by walking the leaves.
+func (t *BPlusTree) Items() []*Item {
+	var res []*Item
+	node := t.Root
+	for !node.IsLeaf {
+		node = node.Children[0]
+	}
+	for node != nil {
+		res = append(res, node.Items...)
+		node = node.Next
+	}
+	return res
+}
@@ -83,3 +83,17 @@ func (c *CMS) Count(item string) uint32 {
 	}
 	return minCount
 }
+
+// Copy returns a sketch with the same dimensions and counters.
+func (c *CMS) Copy() *CMS {
+	res := CreateCMS(c.width, c.depth)
+	for i := range c.counter {
+		copy(res.counter[i], c.counter[i])
+	}
+	return res
+}
+
+// MemoryUsage returns the approximate bytes held by the sketch.
+func (c *CMS) MemoryUsage() int64 {
+	return 8 + sliceHeaderSize + int64(c.depth)*(sliceHeaderSize+4*int64(c.width))
+}
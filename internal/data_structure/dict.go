@@ -1,214 +1,400 @@
 package data_structure
 
 import (
-	"log"
-	"math/rand"
 	"redis-clone/internal/config"
+	"slices"
 	"time"
 )
 
+// Object types, every key of the keyspace holds exactly one of them.
+const (
+	ObjTypeString uint8 = iota
+	ObjTypeSet
+	ObjTypeZSet
+	ObjTypeCMS
+	ObjTypeBloom
+	ObjTypeList
+)
+
 type Obj struct {
-	Value          interface{}
-	LastAccessTime uint32
-	AccessCount    uint8
-	LastDecayTime  uint32
+	Type  uint8
+	Value interface{}
+	// LRU holds the LRU clock or the LFU counter of the last access, see lru.go
+	LRU uint32
+	// size is the memory accounted for the object and its key by the Dict holding it
+	size int64
 }
 
 type Dict struct {
-	dictStore        map[string]*Obj
-	expiredDictStore map[string]uint64
+	dictStore        *Hashtable[*Obj]
+	expiredDictStore *Hashtable[uint64]
+	// expireIndex orders the keys of expiredDictStore by deadline, so the keys
+	// due first are found without scanning the others
+	expireIndex *Skiplist
+	// expiredKeys counts the keys deleted because their TTL elapsed
+	expiredKeys int64
+	// usedMemory is the sum of the sizes of the objects
+	usedMemory int64
 }
 
 func CreateDict() *Dict {
 	res := Dict{
-		dictStore:        make(map[string]*Obj),
-		expiredDictStore: make(map[string]uint64),
+		dictStore:        NewHashtable[*Obj](),
+		expiredDictStore: NewHashtable[uint64](),
+		expireIndex:      CreateSkiplist(),
 	}
 	return &res
 }
 
-func (d *Dict) GetexpiredDictStore() map[string]uint64 {
+func (d *Dict) GetexpiredDictStore() *Hashtable[uint64] {
 	return d.expiredDictStore
 }
 
-func (d *Dict) GetDictStore() map[string]*Obj {
+func (d *Dict) GetDictStore() *Hashtable[*Obj] {
 	return d.dictStore
 }
 
-func now() uint32 {
-	return uint32(time.Now().Unix())
+// typeOf returns the object type of a value stored in the keyspace.
+func typeOf(value interface{}) uint8 {
+	switch value.(type) {
+	case *SimpleSet:
+		return ObjTypeSet
+	case *SortedSet:
+		return ObjTypeZSet
+	case *CMS:
+		return ObjTypeCMS
+	case *Bloom:
+		return ObjTypeBloom
+	case *Quicklist:
+		return ObjTypeList
+	default:
+		return ObjTypeString
+	}
 }
 
-func (d *Dict) NewObject(key string, value interface{}, ttlMs int64) *Obj {
-	obj := &Obj{
-		Value:          value,
-		LastAccessTime: now(),
-		AccessCount:    1, // Initial counter value
-		LastDecayTime:  now(),
+// DupValue returns a deep copy of a value stored in the keyspace, the copy is stored under key.
+func DupValue(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case *SimpleSet:
+		return v.Copy(key)
+	case *SortedSet:
+		return v.Copy()
+	case *CMS:
+		return v.Copy()
+	case *Bloom:
+		return v.Copy()
+	case *Quicklist:
+		return v.Copy()
+	case []byte:
+		// strings changed in place are the only mutable ones
+		return slices.Clone(v)
+	default:
+		return v
 	}
-	if ttlMs > 0 {
-		d.SetExpired(key, ttlMs)
+}
+
+// NewObject wraps value in an object, its TTL is set separately once it is stored.
+func (d *Dict) NewObject(value interface{}) *Obj {
+	obj := &Obj{
+		Type:  typeOf(value),
+		Value: value,
+		LRU:   initLRU(),
 	}
 	return obj
 }
 
 func (d *Dict) GetExpired(key string) (uint64, bool) {
-	exp, exists := d.expiredDictStore[key]
-	return exp, exists
+	return d.expiredDictStore.Get(key)
 }
 
 func (d *Dict) SetExpired(key string, ttlMs int64) {
-	d.expiredDictStore[key] = uint64(time.Now().UnixMilli()) + uint64(ttlMs)
+	d.SetExpiredAt(key, uint64(time.Now().UnixMilli())+uint64(ttlMs))
 }
 
-func (d *Dict) HasExpired(key string) bool {
-	exp, exist := d.expiredDictStore[key]
+// SetExpiredAt sets the absolute expiry of key, in unix milliseconds.
+func (d *Dict) SetExpiredAt(key string, expireAtMs uint64) {
+	d.removeExpire(key)
+	d.expiredDictStore.Set(key, expireAtMs)
+	// unix milliseconds are exact in a float64 until the year 287396
+	d.expireIndex.Insert(float64(expireAtMs), key)
+}
+
+// removeExpire drops the TTL of key, it returns false when key has none.
+func (d *Dict) removeExpire(key string) bool {
+	exp, exist := d.expiredDictStore.Get(key)
 	if !exist {
 		return false
 	}
-	return exp <= uint64(time.Now().UnixMilli())
+	d.expiredDictStore.Delete(key)
+	d.expireIndex.Delete(float64(exp), key)
+	return true
 }
 
-func (obj *Obj) updateLFUCounter() {
-	currentTime := now()
-	timeDiff := currentTime - obj.LastDecayTime
+// Persist removes the TTL of key, it returns false when key has none.
+func (d *Dict) Persist(key string) bool {
+	return d.removeExpire(key)
+}
 
-	if timeDiff > 0 {
-		numDecays := timeDiff / 60
-		if numDecays > 0 {
-			if obj.AccessCount > uint8(numDecays) {
-				obj.AccessCount -= uint8(numDecays)
-			} else {
-				obj.AccessCount = 0
-			}
-			obj.LastDecayTime = currentTime
-		}
-	}
-	if obj.AccessCount < 255 {
-		baseProbability := 1.0 / float64(obj.AccessCount+1)
-		if rand.Float64() < baseProbability {
-			obj.AccessCount++
-		}
+func (d *Dict) HasExpired(key string) bool {
+	exp, exist := d.expiredDictStore.Get(key)
+	if !exist {
+		return false
 	}
+	return exp <= uint64(time.Now().UnixMilli())
 }
 
-func (d *Dict) Get(key string) *Obj {
-	v := d.dictStore[key]
-	if v != nil {
-		v.LastAccessTime = now()
-		v.updateLFUCounter()
-		if d.HasExpired(key) {
-			d.Delete(key)
-			return nil
-		}
+// ExpireIfNeeded deletes key when its TTL has elapsed and reports whether it did.
+func (d *Dict) ExpireIfNeeded(key string) bool {
+	if !d.HasExpired(key) {
+		return false
 	}
-	return v
+	d.remove(key, config.LazyfreeLazyExpire)
+	d.expiredKeys++
+	return true
+}
+
+// NextExpireAt returns the earliest deadline of the keys with a TTL, in unix milliseconds.
+func (d *Dict) NextExpireAt() (uint64, bool) {
+	score, _, ok := d.expireIndex.First()
+	return uint64(score), ok
 }
 
-func (d *Dict) evictRandom() {
-	evictCount := int64(config.EvictionRatio * float64(config.MaxKeyNumber))
-	log.Print("trigger random eviction")
-	for k := range d.dictStore {
-		d.Delete(k)
-		evictCount--
-		if evictCount == 0 {
+// ActiveExpire deletes up to limit keys whose deadline is not after nowMs, earliest
+// first, and returns how many it deleted. The cost is proportional to the keys deleted.
+func (d *Dict) ActiveExpire(nowMs uint64, limit int) int {
+	deleted := 0
+	for deleted < limit {
+		score, key, ok := d.expireIndex.First()
+		if !ok || uint64(score) > nowMs {
 			break
 		}
+		d.remove(key, config.LazyfreeLazyExpire)
+		d.expiredKeys++
+		deleted++
 	}
+	return deleted
 }
 
-func (d *Dict) populatePool() {
-	remain := config.EpoolLruSampleSize
-	for k := range d.dictStore {
-		ePool.Push(k, d.dictStore[k].LastAccessTime)
-		remain--
-		if remain == 0 {
-			break
-		}
+// ExpiredKeys returns how many keys were deleted because their TTL elapsed.
+func (d *Dict) ExpiredKeys() int64 {
+	return d.expiredKeys
+}
+
+func (d *Dict) Get(key string) *Obj {
+	v, _ := d.dictStore.Get(key)
+	if v == nil {
+		return nil
 	}
-	log.Printf("Epool")
-	for _, item := range ePool.pool {
-		log.Println(item.key, item.lastAccessTime)
+	// keys of every type are expired lazily on access
+	if d.ExpireIfNeeded(key) {
+		return nil
 	}
+	v.touch()
+	return v
 }
 
-func (d *Dict) evictLru() {
-	d.populatePool()
-	evictCount := int64(config.EvictionRatio * float64(config.MaxKeyNumber))
-	log.Print("trigger LRU eviction")
-	for i := 0; i < int(evictCount) && len(ePool.pool) > 0; i++ {
-		item := ePool.Pop()
-		if item != nil {
-			d.Delete(item.key)
-		}
+// GetNoTouch is Get without recording an access, for commands inspecting the object.
+func (d *Dict) GetNoTouch(key string) *Obj {
+	if d.ExpireIfNeeded(key) {
+		return nil
 	}
+	v, _ := d.dictStore.Get(key)
+	return v
 }
 
-func (d *Dict) populatePoolLfu() {
-	lfuEvictionPool.Clear()
-	remain := config.EpoolLfuSampleSize
+// Eviction policies, like Redis maxmemory-policy. The allkeys policies pick among every
+// key, the volatile ones only among the keys with a TTL.
+const (
+	EvictionNoEviction     = "noeviction"
+	EvictionAllKeysRandom  = "allkeys-random"
+	EvictionAllKeysLRU     = "allkeys-lru"
+	EvictionAllKeysLFU     = "allkeys-lfu"
+	EvictionVolatileRandom = "volatile-random"
+	EvictionVolatileLRU    = "volatile-lru"
+	EvictionVolatileLFU    = "volatile-lfu"
+	EvictionVolatileTTL    = "volatile-ttl"
+)
 
-	for k, obj := range d.dictStore {
-		idleTime := now() - obj.LastAccessTime
-		freq := uint64(obj.AccessCount)
-		if idleTime > 0 {
-			idleFactor := idleTime / 60
-			if idleFactor > 0 && freq > 0 {
-				freq = freq / (1 + uint64(idleFactor))
+// ValidEvictionPolicy reports whether policy is one of the supported eviction policies.
+func ValidEvictionPolicy(policy string) bool {
+	switch policy {
+	case EvictionNoEviction, EvictionAllKeysRandom, EvictionAllKeysLRU, EvictionAllKeysLFU,
+		EvictionVolatileRandom, EvictionVolatileLRU, EvictionVolatileLFU, EvictionVolatileTTL:
+		return true
+	}
+	return false
+}
+
+// sampleKeys calls fn with up to n random keys, only keys with a TTL when volatile is set.
+// A key may be picked more than once.
+func (d *Dict) sampleKeys(n int, volatile bool, fn func(k string, obj *Obj)) {
+	if volatile {
+		n = min(n, d.expiredDictStore.Len())
+		for ; n > 0; n-- {
+			k, _, ok := d.expiredDictStore.Random()
+			if !ok {
+				break
+			}
+			if obj, exist := d.dictStore.Get(k); exist {
+				fn(k, obj)
 			}
 		}
-
-		lfuEvictionPool.Push(k, freq)
-		remain--
-		if remain == 0 {
-			break
+		return
+	}
+	n = min(n, d.dictStore.Len())
+	for ; n > 0; n-- {
+		if k, obj, ok := d.dictStore.Random(); ok {
+			fn(k, obj)
 		}
 	}
 }
 
-func (d *Dict) evictLfu() {
-	d.populatePoolLfu()
-	evictCount := int64(config.EvictionRatio * float64(config.MaxKeyNumber))
-	log.Print("trigger LFU eviction")
-
-	for i := 0; i < int(evictCount) && len(lfuEvictionPool.pool) > 0; i++ {
-		item := lfuEvictionPool.Pop()
-		if item != nil {
-			d.Delete(item.key)
-		}
+func (d *Dict) evictRandom(volatile bool) bool {
+	if volatile {
+		k, _, ok := d.expiredDictStore.Random()
+		return ok && d.evictKey(k)
 	}
+	k, _, ok := d.dictStore.Random()
+	return ok && d.evictKey(k)
 }
 
-func (d *Dict) evict() {
-	switch config.EvictionPolicy {
-	case "allkeys-random":
-		d.evictRandom()
-	case "allkeys-lru":
-		d.evictLru()
-	case "allkeys-lfu":
-		d.evictLfu()
-	}
+// evictTTL deletes the key that expires first.
+func (d *Dict) evictTTL() bool {
+	_, k, ok := d.expireIndex.First()
+	return ok && d.evictKey(k)
+}
+
+// evictKey deletes key to reclaim memory.
+func (d *Dict) evictKey(key string) bool {
+	return d.remove(key, config.LazyfreeLazyEviction)
 }
 
+// Set stores obj at key. Like a fresh value in Redis it has no TTL, the TTL of a
+// value it overwrites is dropped.
 func (d *Dict) Set(key string, obj *Obj) {
-	if len(d.dictStore) == config.MaxKeyNumber {
-		d.evict()
+	d.SetKeepTTL(key, obj)
+	d.removeExpire(key)
+}
+
+// SetKeepTTL stores obj at key, keeping the TTL of the value it overwrites.
+func (d *Dict) SetKeepTTL(key string, obj *Obj) {
+	if old, exist := d.dictStore.Get(key); exist {
+		d.usedMemory -= old.size
 	}
-	v := d.dictStore[key]
-	if v == nil {
-		HashKeySpaceStat.Key++
+	obj.size = entrySize(key, obj)
+	d.usedMemory += obj.size
+	d.dictStore.Set(key, obj)
+}
+
+// UpdateSize accounts the memory of the object at key again, after it changed in place.
+func (d *Dict) UpdateSize(key string) {
+	obj, exist := d.dictStore.Get(key)
+	if !exist {
+		return
+	}
+	size := entrySize(key, obj)
+	d.usedMemory += size - obj.size
+	obj.size = size
+}
+
+// MemoryUsage returns the bytes held by key and its value, or false when key does not exist.
+// Inspecting a key is not an access, its LRU and LFU data are left alone.
+func (d *Dict) MemoryUsage(key string) (int64, bool) {
+	obj := d.GetNoTouch(key)
+	if obj == nil {
+		return 0, false
 	}
-	d.dictStore[key] = obj
+	return obj.size, true
+}
+
+// UsedMemory returns the approximate bytes held by the keyspace, tables included.
+func (d *Dict) UsedMemory() int64 {
+	expires := d.expiredDictStore.MemoryOverhead() + int64(d.ExpiresLen())*expireEntrySize
+	return d.usedMemory + d.dictStore.MemoryOverhead() + expires
+}
+
+// MemoryOverhead returns the bytes the main dict and the expires take on top of the
+// keys and their values.
+func (d *Dict) MemoryOverhead() (int64, int64) {
+	main := d.dictStore.MemoryOverhead() + int64(d.Len())*(hashtableEntrySize+objSize)
+	expires := d.expiredDictStore.MemoryOverhead() + int64(d.ExpiresLen())*expireEntrySize
+	return main, expires
 }
 
 func (d *Dict) Delete(key string) bool {
-	log.Printf("Delete key %s", key)
-	if _, exists := d.dictStore[key]; exists {
-		delete(d.dictStore, key)
-		delete(d.expiredDictStore, key)
-		HashKeySpaceStat.Key--
-		return true
+	return d.remove(key, false)
+}
+
+// Unlink deletes key like Delete, but a large value is released in the background.
+func (d *Dict) Unlink(key string) bool {
+	return d.remove(key, true)
+}
+
+// remove detaches key from the keyspace and releases its value, in the background
+// when lazy is set.
+func (d *Dict) remove(key string, lazy bool) bool {
+	obj, exist := d.dictStore.Get(key)
+	if !exist {
+		return false
 	}
-	return false
+	d.usedMemory -= obj.size
+	d.dictStore.Delete(key)
+	d.removeExpire(key)
+	freeObject(obj, lazy)
+	return true
+}
+
+// Len returns the exact number of keys, including expired keys not reclaimed yet.
+func (d *Dict) Len() int {
+	return d.dictStore.Len()
+}
+
+// ExpiresLen returns the number of keys with a TTL.
+func (d *Dict) ExpiresLen() int {
+	return d.expiredDictStore.Len()
+}
+
+// RandomKey returns a random key that has not expired, or false when there is none.
+func (d *Dict) RandomKey() (string, bool) {
+	// give up after a while when almost every key has expired, like Redis does
+	for tries := 0; tries < 100; tries++ {
+		k, _, ok := d.dictStore.Random()
+		if !ok {
+			break
+		}
+		if !d.ExpireIfNeeded(k) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// Flush removes every key.
+func (d *Dict) Flush() {
+	d.reset()
+}
+
+// FlushAsync removes every key right away and releases them in the background.
+func (d *Dict) FlushAsync() {
+	old := d.dictStore
+	d.reset()
+	freeLazy(func() {
+		old.ForEach(func(_ string, obj *Obj) bool {
+			releaseValue(obj.Value)
+			return true
+		})
+		old.release()
+	})
+}
+
+func (d *Dict) reset() {
+	d.dictStore = NewHashtable[*Obj]()
+	d.expiredDictStore = NewHashtable[uint64]()
+	d.expireIndex = CreateSkiplist()
+	d.usedMemory = 0
+}
 
+// Scan visits the keys of one bucket of the keyspace, see Hashtable.Scan.
+func (d *Dict) Scan(cursor uint64, fn func(key string, obj *Obj)) uint64 {
+	return d.dictStore.Scan(cursor, fn)
 }
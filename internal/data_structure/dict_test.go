@@ -0,0 +1,35 @@
+package data_structure_test
+
+import (
+	"redis-clone/internal/data_structure"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDictActiveExpire(t *testing.T) {
+	d := data_structure.CreateDict()
+	for i, key := range []string{"c", "a", "b", "d"} {
+		d.Set(key, d.NewObject("v"))
+		d.SetExpiredAt(key, uint64(100*(i+1)))
+	}
+	// moving a deadline reorders the key
+	d.SetExpiredAt("c", 1000)
+	at, ok := d.NextExpireAt()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(200), at)
+
+	assert.Equal(t, 1, d.ActiveExpire(300, 1))
+	assert.Equal(t, 1, d.ActiveExpire(300, 10))
+	assert.Equal(t, 0, d.ActiveExpire(300, 10))
+	assert.Equal(t, int64(2), d.ExpiredKeys())
+	assert.Equal(t, 2, d.Len())
+	assert.Equal(t, 2, d.ExpiresLen())
+
+	// dropping the TTL removes the key from the index
+	assert.True(t, d.Persist("d"))
+	d.Set("c", d.NewObject("v"))
+	_, ok = d.NextExpireAt()
+	assert.False(t, ok)
+	assert.Equal(t, 0, d.ActiveExpire(10000, 10))
+}
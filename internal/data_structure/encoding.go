@@ -0,0 +1,138 @@
+package data_structure
+
+import (
+	"slices"
+	"strconv"
+)
+
+// Object encodings, the internal representation used for a type. Small aggregates use
+// compact encodings and convert once they grow past the thresholds of config.
+const (
+	ObjEncodingRaw uint8 = iota
+	ObjEncodingInt
+	ObjEncodingEmbstr
+	ObjEncodingHashtable
+	ObjEncodingIntset
+	ObjEncodingListpack
+	ObjEncodingBPlusTree
+	ObjEncodingQuicklist
+)
+
+// embstrSizeLimit is the longest string Redis allocates together with its object.
+const embstrSizeLimit = 44
+
+var encodingNames = map[uint8]string{
+	ObjEncodingRaw:       "raw",
+	ObjEncodingInt:       "int",
+	ObjEncodingEmbstr:    "embstr",
+	ObjEncodingHashtable: "hashtable",
+	ObjEncodingIntset:    "intset",
+	ObjEncodingListpack:  "listpack",
+	ObjEncodingBPlusTree: "bplustree",
+	ObjEncodingQuicklist: "quicklist",
+}
+
+// EncodingName returns the name OBJECT ENCODING reports for an encoding.
+func EncodingName(encoding uint8) string {
+	return encodingNames[encoding]
+}
+
+// Encoding returns the current encoding of the object value.
+func (obj *Obj) Encoding() uint8 {
+	switch v := obj.Value.(type) {
+	case int64:
+		return ObjEncodingInt
+	case string:
+		if len(v) <= embstrSizeLimit {
+			return ObjEncodingEmbstr
+		}
+		return ObjEncodingRaw
+	case *SimpleSet:
+		return v.Encoding()
+	case *SortedSet:
+		return v.Encoding()
+	case *Quicklist:
+		return v.Encoding()
+	}
+	return ObjEncodingRaw
+}
+
+// NewString returns the value stored for the string s, the integer itself when s is
+// one so counters are not parsed again on every update.
+func NewString(s string) interface{} {
+	if v, isInt := parseCanonicalInt(s); isInt {
+		return v
+	}
+	return s
+}
+
+// StringValue returns the bytes of a string value.
+func StringValue(value interface{}) string {
+	switch v := value.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case []byte:
+		return string(v)
+	}
+	return value.(string)
+}
+
+// StringBytes returns the bytes of a string value for reading, a value already changed
+// in place is returned without a copy so the result must not be modified.
+func StringBytes(value interface{}) []byte {
+	if v, isBuf := value.([]byte); isBuf {
+		return v
+	}
+	return []byte(StringValue(value))
+}
+
+// StringLen returns the length of a string value.
+func StringLen(value interface{}) int {
+	switch v := value.(type) {
+	case []byte:
+		return len(v)
+	case string:
+		return len(v)
+	}
+	return len(StringValue(value))
+}
+
+// MutableString returns a string value as a buffer to change in place, grown with zero
+// bytes to at least size bytes. Like an sds in Redis the buffer keeps spare capacity,
+// so growing it again is amortized O(1). The caller stores the result back as the value,
+// only the first call on a string or an integer copies it.
+func MutableString(value interface{}, size int) []byte {
+	buf, isBuf := value.([]byte)
+	if !isBuf && value != nil {
+		buf = []byte(StringValue(value))
+	}
+	if n := len(buf); size > n {
+		buf = slices.Grow(buf, size-n)[:size]
+		clear(buf[n:])
+	}
+	return buf
+}
+
+// IntValue returns the integer held by a string value, false when it is not an integer.
+func IntValue(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case []byte:
+		return parseCanonicalInt(string(v))
+	}
+	return parseCanonicalInt(value.(string))
+}
+
+// parseCanonicalInt parses s as an int64 only when formatting the integer back gives
+// s, so storing the integer instead of s loses nothing.
+func parseCanonicalInt(s string) (int64, bool) {
+	if len(s) == 0 || len(s) > 20 {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || strconv.FormatInt(v, 10) != s {
+		return 0, false
+	}
+	return v, true
+}
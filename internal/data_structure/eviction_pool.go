@@ -0,0 +1,155 @@
+package data_structure
+
+import (
+	"math"
+	"redis-clone/internal/config"
+	"slices"
+)
+
+// EvictionCandidate is a sampled key, the higher idle the better it is to evict it.
+type EvictionCandidate struct {
+	idle uint64
+	key  string
+	// db is the database holding key, not its index: SWAPDB moves databases between
+	// indexes while candidates wait in the pool
+	db *Dict
+}
+
+// EvictionPool keeps the best candidates of the successive samplings, like the
+// eviction pool of Redis, so each eviction benefits from the keys sampled before.
+// It is shared by every database.
+type EvictionPool struct {
+	// pool is sorted by ascending idle, the best candidate is last
+	pool []*EvictionCandidate
+}
+
+func (c *EvictionCandidate) Key() string {
+	return c.key
+}
+
+// Push inserts a candidate at its position. When the pool is full the worst candidate
+// is dropped, or the new one if it is worse than all of them.
+func (p *EvictionPool) Push(key string, db *Dict, idle uint64) {
+	for i, c := range p.pool {
+		// the key may be sampled again, it gets its new idle
+		if c.key == key && c.db == db {
+			p.pool = append(p.pool[:i], p.pool[i+1:]...)
+			break
+		}
+	}
+	i := 0
+	for i < len(p.pool) && p.pool[i].idle < idle {
+		i++
+	}
+	if len(p.pool) >= config.EpoolMaxSize {
+		if i == 0 {
+			return
+		}
+		// make room by dropping the worst candidate, on the left
+		copy(p.pool, p.pool[1:i])
+		i--
+		p.pool[i] = &EvictionCandidate{idle: idle, key: key, db: db}
+		return
+	}
+	p.pool = append(p.pool, nil)
+	copy(p.pool[i+1:], p.pool[i:])
+	p.pool[i] = &EvictionCandidate{idle: idle, key: key, db: db}
+}
+
+// Pop removes and returns the best candidate.
+func (p *EvictionPool) Pop() *EvictionCandidate {
+	if len(p.pool) == 0 {
+		return nil
+	}
+	best := p.pool[len(p.pool)-1]
+	p.pool = p.pool[:len(p.pool)-1]
+	return best
+}
+
+func (p *EvictionPool) Clear() {
+	p.pool = p.pool[:0]
+}
+
+func newEpool(size int) *EvictionPool {
+	return &EvictionPool{
+		pool: make([]*EvictionCandidate, 0, size),
+	}
+}
+
+var ePool *EvictionPool = newEpool(config.EpoolMaxSize)
+
+// nextEvictDB is the database the random policies evict from next, they take turns.
+var nextEvictDB int
+
+// Evict deletes one key of dbs picked by config.EvictionPolicy, it returns false when no
+// key could be evicted. Nothing is evicted under noeviction or an unknown policy.
+func Evict(dbs []*Dict) bool {
+	policy := config.EvictionPolicy
+	volatile := policy == EvictionVolatileRandom || policy == EvictionVolatileLRU || policy == EvictionVolatileLFU
+	switch policy {
+	case EvictionAllKeysRandom, EvictionVolatileRandom:
+		for i := 0; i < len(dbs); i++ {
+			db := dbs[nextEvictDB%len(dbs)]
+			nextEvictDB = (nextEvictDB + 1) % len(dbs)
+			if db.evictRandom(volatile) {
+				return true
+			}
+		}
+		return false
+	case EvictionVolatileTTL:
+		// the expire index gives the key expiring first without sampling
+		var best *Dict
+		var bestAt uint64
+		for _, db := range dbs {
+			if at, ok := db.NextExpireAt(); ok && (best == nil || at < bestAt) {
+				best, bestAt = db, at
+			}
+		}
+		return best != nil && best.evictTTL()
+	case EvictionAllKeysLRU, EvictionAllKeysLFU, EvictionVolatileLRU, EvictionVolatileLFU:
+		for {
+			sampled := 0
+			for _, db := range dbs {
+				sampled += db.populateEvictionPool(volatile)
+			}
+			if sampled == 0 {
+				return false
+			}
+			for c := ePool.Pop(); c != nil; c = ePool.Pop() {
+				// candidates may have been deleted, or lost their TTL, since they were sampled
+				if !slices.Contains(dbs, c.db) || !c.db.isEvictable(c.key, volatile) {
+					continue
+				}
+				return c.db.evictKey(c.key)
+			}
+		}
+	}
+	return false
+}
+
+// populateEvictionPool samples config.MaxMemorySamples keys of d into the eviction pool
+// and returns how many it sampled.
+func (d *Dict) populateEvictionPool(volatile bool) int {
+	sampled := 0
+	d.sampleKeys(config.MaxMemorySamples, volatile, func(k string, obj *Obj) {
+		var idle uint64
+		if LFUEnabled() {
+			idle = math.MaxUint8 - uint64(obj.lfuDecrAndReturn())
+		} else {
+			idle = uint64(obj.IdleTime())
+		}
+		ePool.Push(k, d, idle)
+		sampled++
+	})
+	return sampled
+}
+
+// isEvictable reports whether key is still in d, with a TTL when volatile is set.
+func (d *Dict) isEvictable(key string, volatile bool) bool {
+	if volatile {
+		_, exist := d.expiredDictStore.Get(key)
+		return exist
+	}
+	_, exist := d.dictStore.Get(key)
+	return exist
+}
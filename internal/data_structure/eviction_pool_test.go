@@ -0,0 +1,91 @@
+package data_structure_test
+
+import (
+	"fmt"
+	"redis-clone/internal/config"
+	"redis-clone/internal/data_structure"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvictionPool(t *testing.T) {
+	defer func(n int) { config.EpoolMaxSize = n }(config.EpoolMaxSize)
+	config.EpoolMaxSize = 3
+	p := &data_structure.EvictionPool{}
+	d := data_structure.CreateDict()
+	p.Push("b", d, 20)
+	p.Push("a", d, 10)
+	p.Push("d", d, 40)
+	// the pool is full, the worst candidate makes room
+	p.Push("c", d, 30)
+	// worse than every candidate
+	p.Push("e", d, 5)
+	// sampled again with a new idle
+	p.Push("b", d, 50)
+
+	var keys []string
+	for c := p.Pop(); c != nil; c = p.Pop() {
+		keys = append(keys, c.Key())
+	}
+	assert.Equal(t, []string{"b", "d", "c"}, keys)
+}
+
+func TestEvictVolatileRandomIsUniform(t *testing.T) {
+	defer func(policy string) { config.EvictionPolicy = policy }(config.EvictionPolicy)
+	config.EvictionPolicy = data_structure.EvictionVolatileRandom
+	counts := map[string]int{}
+	for trial := 0; trial < 2000; trial++ {
+		d := data_structure.CreateDict()
+		for i := 0; i < 100; i++ {
+			d.Set(fmt.Sprintf("persistent:%d", i), d.NewObject("v"))
+		}
+		for i := 0; i < 10; i++ {
+			key := fmt.Sprintf("volatile:%d", i)
+			d.Set(key, d.NewObject("v"))
+			d.SetExpired(key, 60000)
+		}
+		assert.True(t, data_structure.Evict([]*data_structure.Dict{d}))
+		for i := 0; i < 10; i++ {
+			key := fmt.Sprintf("volatile:%d", i)
+			if d.GetNoTouch(key) == nil {
+				counts[key]++
+			}
+		}
+		assert.Equal(t, 109, d.Len())
+	}
+	// every key with a TTL has the same chance, about 200 times each
+	assert.Len(t, counts, 10)
+	for key, n := range counts {
+		assert.Greater(t, n, 120, key)
+	}
+}
+
+func TestEvictAfterSwapDB(t *testing.T) {
+	defer func(policy string, samples int) {
+		config.EvictionPolicy, config.MaxMemorySamples = policy, samples
+	}(config.EvictionPolicy, config.MaxMemorySamples)
+	config.EvictionPolicy, config.MaxMemorySamples = data_structure.EvictionAllKeysLRU, 1
+	a, b, c := data_structure.CreateDict(), data_structure.CreateDict(), data_structure.CreateDict()
+	set := func(d *data_structure.Dict, key string, idle time.Duration) {
+		obj := d.NewObject("v")
+		obj.SetIdleTime(idle)
+		d.Set(key, obj)
+	}
+	set(a, "k", 900*time.Second)
+	set(b, "k", 0)
+	for i := 0; i < 1000; i++ {
+		set(b, fmt.Sprintf("fresh:%d", i), 0)
+	}
+	// evicts x and leaves a candidate for k of a in the pool
+	set(c, "x", 1000*time.Second)
+	assert.True(t, data_structure.Evict([]*data_structure.Dict{a, c}))
+	assert.Equal(t, 0, c.Len())
+
+	// like SWAPDB, the databases trade their indexes
+	assert.True(t, data_structure.Evict([]*data_structure.Dict{b, a}))
+	assert.Nil(t, a.GetNoTouch("k"))
+	assert.NotNil(t, b.GetNoTouch("k"))
+	assert.Equal(t, 1001, b.Len())
+}
@@ -0,0 +1,167 @@
+package data_structure
+
+import (
+	"hash/maphash"
+	"math/bits"
+	"math/rand"
+)
+
+const hashtableInitialSize = 4
+
+type hashtableEntry[V any] struct {
+	key   string
+	value V
+	next  *hashtableEntry[V]
+}
+
+// Hashtable is a chained hash table with a power of two number of buckets. Unlike a
+// Go map its iteration order only depends on the keys and the table size, which lets
+// Scan give the same guarantees as dictScan in Redis: a full scan returns every key
+// present for the whole scan at least once, even when the table grows or shrinks
+// between calls.
+type Hashtable[V any] struct {
+	buckets []*hashtableEntry[V]
+	size    int
+	seed    maphash.Seed
+	// iterators counts running ForEach calls, resizing is paused while they run
+	iterators int
+}
+
+func NewHashtable[V any]() *Hashtable[V] {
+	return &Hashtable[V]{
+		buckets: make([]*hashtableEntry[V], hashtableInitialSize),
+		seed:    maphash.MakeSeed(),
+	}
+}
+
+func (h *Hashtable[V]) bucketIndex(key string) uint64 {
+	return maphash.String(h.seed, key) & uint64(len(h.buckets)-1)
+}
+
+func (h *Hashtable[V]) Len() int {
+	return h.size
+}
+
+func (h *Hashtable[V]) Get(key string) (V, bool) {
+	for e := h.buckets[h.bucketIndex(key)]; e != nil; e = e.next {
+		if e.key == key {
+			return e.value, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// Set inserts or replaces the value of key, it returns true when key is new.
+func (h *Hashtable[V]) Set(key string, value V) bool {
+	idx := h.bucketIndex(key)
+	for e := h.buckets[idx]; e != nil; e = e.next {
+		if e.key == key {
+			e.value = value
+			return false
+		}
+	}
+	h.buckets[idx] = &hashtableEntry[V]{key: key, value: value, next: h.buckets[idx]}
+	h.size++
+	// grow once the load factor reaches 1
+	if h.size >= len(h.buckets) {
+		h.resize(len(h.buckets) * 2)
+	}
+	return true
+}
+
+// Delete removes key, it returns false when key was not present.
+func (h *Hashtable[V]) Delete(key string) bool {
+	idx := h.bucketIndex(key)
+	var prev *hashtableEntry[V]
+	for e := h.buckets[idx]; e != nil; e = e.next {
+		if e.key != key {
+			prev = e
+			continue
+		}
+		if prev == nil {
+			h.buckets[idx] = e.next
+		} else {
+			prev.next = e.next
+		}
+		h.size--
+		// shrink once less than 1/8 of the buckets would be used
+		if len(h.buckets) > hashtableInitialSize && h.size < len(h.buckets)/8 {
+			h.resize(len(h.buckets) / 2)
+		}
+		return true
+	}
+	return false
+}
+
+func (h *Hashtable[V]) resize(n int) {
+	if h.iterators > 0 {
+		return
+	}
+	buckets := make([]*hashtableEntry[V], n)
+	mask := uint64(n - 1)
+	for _, e := range h.buckets {
+		for e != nil {
+			next := e.next
+			idx := maphash.String(h.seed, e.key) & mask
+			e.next = buckets[idx]
+			buckets[idx] = e
+			e = next
+		}
+	}
+	h.buckets = buckets
+}
+
+// ForEach calls fn for every entry until fn returns false. fn may delete the key it is
+// called with.
+func (h *Hashtable[V]) ForEach(fn func(key string, value V) bool) {
+	h.iterators++
+	defer func() { h.iterators-- }()
+	for i := 0; i < len(h.buckets); i++ {
+		for e := h.buckets[i]; e != nil; {
+			next := e.next
+			if !fn(e.key, e.value) {
+				return
+			}
+			e = next
+		}
+	}
+}
+
+// Scan calls fn for the entries of the bucket at cursor and returns the next cursor,
+// 0 once the whole table has been visited. The cursor is incremented on its reversed
+// bits, so buckets that split or merge on a resize are never skipped.
+func (h *Hashtable[V]) Scan(cursor uint64, fn func(key string, value V)) uint64 {
+	mask := uint64(len(h.buckets) - 1)
+	for e := h.buckets[cursor&mask]; e != nil; {
+		next := e.next
+		fn(e.key, e.value)
+		e = next
+	}
+	// set the unmasked bits so incrementing the reversed cursor carries into the masked ones
+	cursor |= ^mask
+	cursor = bits.Reverse64(cursor)
+	cursor++
+	return bits.Reverse64(cursor)
+}
+
+// Random returns a random entry, or false when the table is empty.
+func (h *Hashtable[V]) Random() (string, V, bool) {
+	if h.size == 0 {
+		var zero V
+		return "", zero, false
+	}
+	var e *hashtableEntry[V]
+	for e == nil {
+		e = h.buckets[rand.Intn(len(h.buckets))]
+	}
+	// pick uniformly inside the chain
+	chainLen := 0
+	for x := e; x != nil; x = x.next {
+		chainLen++
+	}
+	for i := rand.Intn(chainLen); i > 0; i-- {
+		e = e.next
+	}
+	return e.key, e.value, true
+}
@@ -0,0 +1,64 @@
+package data_structure_test
+
+import (
+	"fmt"
+	"redis-clone/internal/data_structure"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashtableBasic(t *testing.T) {
+	h := data_structure.NewHashtable[int]()
+	for i := 0; i < 1000; i++ {
+		assert.True(t, h.Set(fmt.Sprint(i), i))
+	}
+	assert.False(t, h.Set("5", 50))
+	assert.Equal(t, 1000, h.Len())
+	v, ok := h.Get("5")
+	assert.True(t, ok)
+	assert.Equal(t, 50, v)
+	for i := 0; i < 990; i++ {
+		assert.True(t, h.Delete(fmt.Sprint(i)))
+	}
+	assert.False(t, h.Delete("1"))
+	assert.Equal(t, 10, h.Len())
+	_, ok = h.Get("995")
+	assert.True(t, ok)
+}
+
+// Every key present for the whole scan must be returned even when the table grows
+// and shrinks between calls.
+func TestHashtableScanWhileResizing(t *testing.T) {
+	h := data_structure.NewHashtable[struct{}]()
+	for i := 0; i < 500; i++ {
+		h.Set(fmt.Sprintf("stable:%d", i), struct{}{})
+	}
+	seen := map[string]bool{}
+	var cursor uint64
+	step := 0
+	for {
+		cursor = h.Scan(cursor, func(key string, _ struct{}) {
+			seen[key] = true
+		})
+		step++
+		switch {
+		case step < 50:
+			// grow
+			for i := 0; i < 100; i++ {
+				h.Set(fmt.Sprintf("temp:%d:%d", step, i), struct{}{})
+			}
+		case step < 100:
+			// shrink back
+			for i := 0; i < 100; i++ {
+				h.Delete(fmt.Sprintf("temp:%d:%d", step-49, i))
+			}
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	for i := 0; i < 500; i++ {
+		assert.True(t, seen[fmt.Sprintf("stable:%d", i)], "stable:%d not returned", i)
+	}
+}
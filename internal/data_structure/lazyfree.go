@@ -0,0 +1,119 @@
+package data_structure
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// LazyfreeThreshold is the free effort above which a value is released in the
+// background, like LAZYFREE_THRESHOLD in Redis. Smaller values are cheaper to release
+// right away than to hand over.
+const LazyfreeThreshold = 64
+
+var lazyfree struct {
+	once sync.Once
+	jobs chan func()
+	// pending counts the values handed over and not released yet
+	pending atomic.Int64
+	// freed counts the values released in the background
+	freed atomic.Int64
+}
+
+// LazyfreePendingObjects returns how many values wait to be released in the background.
+func LazyfreePendingObjects() int64 {
+	return lazyfree.pending.Load()
+}
+
+// LazyfreedObjects returns how many values were released in the background.
+func LazyfreedObjects() int64 {
+	return lazyfree.freed.Load()
+}
+
+// freeLazy runs release on the background goroutine, once nothing else references
+// what it releases.
+func freeLazy(release func()) {
+	lazyfree.once.Do(func() {
+		lazyfree.jobs = make(chan func(), 1024)
+		go func() {
+			for job := range lazyfree.jobs {
+				job()
+				lazyfree.pending.Add(-1)
+				lazyfree.freed.Add(1)
+			}
+		}()
+	})
+	lazyfree.pending.Add(1)
+	lazyfree.jobs <- release
+}
+
+// freeEffort approximates the work releasing value takes, the number of allocations
+// its memory is spread over.
+func freeEffort(value interface{}) int {
+	switch v := value.(type) {
+	case *SimpleSet:
+		if v.isIntset() {
+			return 1
+		}
+		return v.Len()
+	case *SortedSet:
+		if v.isListpack() {
+			return 1
+		}
+		return v.Len()
+	case *CMS:
+		return int(v.depth)
+	case *Quicklist:
+		// like Redis, the nodes are what has to be released
+		return v.nodes
+	}
+	// strings and the bit array of a bloom filter are a single allocation
+	return 1
+}
+
+// freeObject releases the value of obj, in the background when lazy is set and it is
+// worth it.
+func freeObject(obj *Obj, lazy bool) {
+	if !lazy || freeEffort(obj.Value) <= LazyfreeThreshold {
+		return
+	}
+	value := obj.Value
+	freeLazy(func() {
+		releaseValue(value)
+	})
+}
+
+// releaseValue tears down the allocations of value, leaving the garbage collector
+// only unreferenced memory to reclaim.
+func releaseValue(value interface{}) {
+	switch v := value.(type) {
+	case *SimpleSet:
+		if v.dict != nil {
+			v.dict.release()
+		}
+		v.intset = nil
+	case *SortedSet:
+		if v.MemberScores != nil {
+			v.MemberScores.release()
+		}
+		v.Tree, v.listpack = nil, nil
+	case *CMS:
+		v.counter = nil
+	case *Bloom:
+		v.bf = nil
+	case *Quicklist:
+		v.release()
+	}
+}
+
+// release unlinks every entry of h, the table must not be used afterwards.
+func (h *Hashtable[V]) release() {
+	for i, e := range h.buckets {
+		for e != nil {
+			next := e.next
+			e.next = nil
+			e = next
+		}
+		h.buckets[i] = nil
+	}
+	h.buckets, h.size = nil, 0
+}
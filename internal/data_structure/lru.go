@@ -0,0 +1,127 @@
+package data_structure
+
+import (
+	"math/rand"
+	"redis-clone/internal/config"
+	"strings"
+	"time"
+)
+
+// The 24 bits of Obj.LRU hold, like in Redis, either the LRU clock of the last access
+// or, under an LFU policy, the time of the last decrement in minutes (16 bits) and a
+// logarithmic access counter (8 bits).
+const (
+	LRUBits = 24
+	// LRUClockMax is where the LRU clock wraps around
+	LRUClockMax = 1<<LRUBits - 1
+	// LRUClockResolution is the duration of one LRU clock tick
+	LRUClockResolution = time.Second
+	// LFUInitVal is the counter of new objects, so they are not evicted before they
+	// have a chance to be accessed
+	LFUInitVal = 5
+)
+
+// LRUClock returns the current LRU clock.
+func LRUClock() uint32 {
+	return uint32(time.Now().UnixNano()/int64(LRUClockResolution)) & LRUClockMax
+}
+
+// LFUEnabled reports whether the eviction policy tracks access frequency instead of recency.
+func LFUEnabled() bool {
+	return strings.HasSuffix(config.EvictionPolicy, "-lfu")
+}
+
+// initLRU returns the LRU field of a new object.
+func initLRU() uint32 {
+	if LFUEnabled() {
+		return lfuTimeInMinutes()<<8 | LFUInitVal
+	}
+	return LRUClock()
+}
+
+// touch records an access to obj.
+func (obj *Obj) touch() {
+	if LFUEnabled() {
+		counter := lfuLogIncr(obj.lfuDecrAndReturn())
+		obj.LRU = lfuTimeInMinutes()<<8 | uint32(counter)
+		return
+	}
+	obj.LRU = LRUClock()
+}
+
+// IdleTime returns how long ago obj was last accessed, according to the LRU clock.
+func (obj *Obj) IdleTime() time.Duration {
+	clock := LRUClock()
+	if clock >= obj.LRU {
+		return time.Duration(clock-obj.LRU) * LRUClockResolution
+	}
+	// the clock wrapped around since the access
+	return time.Duration(clock+(LRUClockMax-obj.LRU)) * LRUClockResolution
+}
+
+// Freq returns the access frequency counter of obj, decayed to now.
+func (obj *Obj) Freq() uint8 {
+	return obj.lfuDecrAndReturn()
+}
+
+// SetIdleTime makes obj look last accessed idle ago, like RESTORE IDLETIME.
+func (obj *Obj) SetIdleTime(idle time.Duration) {
+	ticks := uint32(int64(idle/LRUClockResolution) % (LRUClockMax + 1))
+	clock := LRUClock()
+	if clock >= ticks {
+		obj.LRU = clock - ticks
+	} else {
+		obj.LRU = LRUClockMax - (ticks - clock)
+	}
+}
+
+// SetFreq sets the access frequency counter of obj, like RESTORE FREQ.
+func (obj *Obj) SetFreq(freq uint8) {
+	obj.LRU = lfuTimeInMinutes()<<8 | uint32(freq)
+}
+
+// lfuTimeInMinutes returns the 16 bits of the unix time in minutes stored with the LFU counter.
+func lfuTimeInMinutes() uint32 {
+	return uint32(time.Now().Unix()/60) & 65535
+}
+
+// lfuTimeElapsed returns the minutes since ldt, taking the wrap around into account.
+func lfuTimeElapsed(ldt uint32) uint32 {
+	now := lfuTimeInMinutes()
+	if now >= ldt {
+		return now - ldt
+	}
+	return 65535 - ldt + now
+}
+
+// lfuLogIncr increments counter with a probability that shrinks as it grows, so the 8
+// bits cover up to millions of accesses depending on config.LfuLogFactor.
+func lfuLogIncr(counter uint8) uint8 {
+	if counter == 255 {
+		return 255
+	}
+	baseval := float64(counter) - LFUInitVal
+	if baseval < 0 {
+		baseval = 0
+	}
+	p := 1.0 / (baseval*float64(config.LfuLogFactor) + 1)
+	if rand.Float64() < p {
+		counter++
+	}
+	return counter
+}
+
+// lfuDecrAndReturn returns the counter of obj decremented once per config.LfuDecayTime
+// minutes elapsed since the last decrement. The object itself is not updated.
+func (obj *Obj) lfuDecrAndReturn() uint8 {
+	ldt := obj.LRU >> 8
+	counter := obj.LRU & 255
+	if config.LfuDecayTime <= 0 {
+		return uint8(counter)
+	}
+	periods := lfuTimeElapsed(ldt) / uint32(config.LfuDecayTime)
+	if periods >= counter {
+		return 0
+	}
+	return uint8(counter - periods)
+}
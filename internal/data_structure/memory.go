@@ -0,0 +1,55 @@
+package data_structure
+
+// Approximate sizes, in bytes, of the allocations behind the keyspace on a 64-bit
+// platform. They are good enough for maxmemory and MEMORY USAGE, the rounding done
+// by the allocator is ignored.
+const (
+	pointerSize      = 8
+	stringHeaderSize = 16
+	sliceHeaderSize  = 24
+	// a hash table entry holds the key header and the next pointer, plus its value
+	hashtableEntrySize = stringHeaderSize + pointerSize
+	// Obj with its fields padded, the value being an interface
+	objSize = 48
+	// a sorted set member lives in the B+ tree, an Item and a pointer to it, and in
+	// the member to score dict
+	zsetMemberSize = pointerSize + stringHeaderSize + 8 + hashtableEntrySize + 8
+	// an expire entry lives in the expiredDictStore hash table, with its deadline as the
+	// value, and in the expire index
+	expireEntrySize = hashtableEntrySize + 8 + 64
+)
+
+// MemoryOverhead returns the bytes used by the table itself, its entries excluded.
+func (h *Hashtable[V]) MemoryOverhead() int64 {
+	return int64(len(h.buckets))*pointerSize + 48
+}
+
+// valueSize returns the bytes held by a value stored in the keyspace. Aggregates keep
+// their size up to date as they change so this is O(1).
+func valueSize(value interface{}) int64 {
+	switch v := value.(type) {
+	case string:
+		return stringHeaderSize + int64(len(v))
+	case int64:
+		return 8
+	case []byte:
+		// the spare capacity kept for appends is held too
+		return sliceHeaderSize + int64(cap(v))
+	case *SimpleSet:
+		return v.MemoryUsage()
+	case *SortedSet:
+		return v.MemoryUsage()
+	case *CMS:
+		return v.MemoryUsage()
+	case *Bloom:
+		return v.MemoryUsage()
+	case *Quicklist:
+		return v.MemoryUsage()
+	}
+	return 0
+}
+
+// entrySize returns the bytes held by key and its object.
+func entrySize(key string, obj *Obj) int64 {
+	return hashtableEntrySize + int64(len(key)) + objSize + valueSize(obj.Value)
+}
@@ -0,0 +1,584 @@
+package data_structure
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"redis-clone/internal/config"
+	"slices"
+)
+
+// quicklistNodeSizes are the byte limits of a node for the negative values of
+// config.ListMaxListpackSize, -1 to -5, like in Redis.
+var quicklistNodeSizes = [...]int{4096, 8192, 16384, 32768, 65536}
+
+// quicklistNodeSize is the quicklistNode struct, its buffer excluded.
+const quicklistNodeSize = 2*pointerSize + sliceHeaderSize + 16
+
+// quicklistMinShrink is the smallest buffer worth reallocating once it is mostly empty.
+const quicklistMinShrink = 64
+
+// quicklistNode packs a run of consecutive elements in one buffer, the counterpart of a
+// Redis listpack. Each entry is the uvarint of its length, its bytes, then the size of
+// both as a uvarint written backwards, so the entries can be walked in both directions.
+type quicklistNode struct {
+	prev, next *quicklistNode
+	// buf holds the entries from start, the bytes before it are entries popped from the
+	// head, reused by the next push at the head or reclaimed by compact
+	buf   []byte
+	start int
+	count int
+}
+
+// Quicklist is a doubly linked list of packed nodes of up to config.ListMaxListpackSize
+// elements each. Pushing and popping at both ends is O(1), and an element costs its
+// bytes and a few bytes of header instead of a string of its own.
+type Quicklist struct {
+	head, tail *quicklistNode
+	count      int
+	nodes      int
+	// bytes is the capacity of the node buffers, used for memory accounting
+	bytes int64
+}
+
+func NewQuicklist() *Quicklist {
+	return &Quicklist{}
+}
+
+func uvarintLen(n uint64) int {
+	return (bits.Len64(n|1) + 6) / 7
+}
+
+// packedSize returns the bytes an entry of value takes in a node.
+func packedSize(value string) int {
+	n := uvarintLen(uint64(len(value))) + len(value)
+	return n + uvarintLen(uint64(n))
+}
+
+// putEntry packs value into dst, which is exactly packedSize(value) bytes long.
+func putEntry(dst []byte, value string) {
+	n := binary.PutUvarint(dst, uint64(len(value)))
+	n += copy(dst[n:], value)
+	var back [binary.MaxVarintLen64]byte
+	k := binary.PutUvarint(back[:], uint64(n))
+	for i := 0; i < k; i++ {
+		dst[len(dst)-1-i] = back[i]
+	}
+}
+
+// entryAt returns the bytes of the entry at off of buf and the offset of the next one.
+func entryAt(buf []byte, off int) ([]byte, int) {
+	length, h := binary.Uvarint(buf[off:])
+	end := off + h + int(length)
+	return buf[off+h : end], end + uvarintLen(uint64(end-off))
+}
+
+// prevEntry returns the offset of the entry of buf that ends at end.
+func prevEntry(buf []byte, end int) int {
+	var size uint64
+	i := end - 1
+	for shift := 0; ; shift += 7 {
+		b := buf[i]
+		size |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			break
+		}
+		i--
+	}
+	return i - int(size)
+}
+
+// live returns the packed entries of node.
+func (node *quicklistNode) live() []byte {
+	return node.buf[node.start:]
+}
+
+func (node *quicklistNode) size() int {
+	return len(node.buf) - node.start
+}
+
+// offset returns the position in live() of entry i, 0 <= i <= count, walking from the
+// closest end of the node.
+func (node *quicklistNode) offset(i int) int {
+	live := node.live()
+	if i <= node.count/2 {
+		off := 0
+		for ; i > 0; i-- {
+			_, off = entryAt(live, off)
+		}
+		return off
+	}
+	off := len(live)
+	for i = node.count - i; i > 0; i-- {
+		off = prevEntry(live, off)
+	}
+	return off
+}
+
+// nodeLimit returns the entry limit of a node, or its byte limit when that is 0, from
+// config.ListMaxListpackSize.
+func nodeLimit() (entries int, size int) {
+	fill := config.ListMaxListpackSize
+	if fill >= 0 {
+		return max(fill, 1), 0
+	}
+	return 0, quicklistNodeSizes[min(-fill, len(quicklistNodeSizes))-1]
+}
+
+// allowInsert reports whether node has room for one more entry of size bytes.
+func (node *quicklistNode) allowInsert(size int) bool {
+	entries, limit := nodeLimit()
+	if entries > 0 {
+		return node.count < entries
+	}
+	// a node always takes at least one entry, however big
+	return node.count == 0 || node.size()+size <= limit
+}
+
+// fits reports whether node can absorb every entry of other.
+func (node *quicklistNode) fits(other *quicklistNode) bool {
+	entries, limit := nodeLimit()
+	if entries > 0 {
+		return node.count+other.count <= entries
+	}
+	return node.size()+other.size() <= limit
+}
+
+// setBuf replaces the buffer of node, keeping the accounted capacity up to date.
+func (q *Quicklist) setBuf(node *quicklistNode, buf []byte) {
+	q.bytes += int64(cap(buf) - cap(node.buf))
+	node.buf = buf
+}
+
+// openGap makes room for n bytes at off of the entries of node and returns it. A gap at
+// the head reuses the space of popped entries when there is enough.
+func (q *Quicklist) openGap(node *quicklistNode, off, n int) []byte {
+	if off == 0 && node.start >= n {
+		node.start -= n
+		return node.buf[node.start : node.start+n]
+	}
+	pos, end := node.start+off, len(node.buf)
+	buf := slices.Grow(node.buf, n)[:end+n]
+	copy(buf[pos+n:], buf[pos:end])
+	q.setBuf(node, buf)
+	return buf[pos : pos+n]
+}
+
+// deleteRange removes the n bytes at off of the entries of node, the head ones in O(1).
+func (q *Quicklist) deleteRange(node *quicklistNode, off, n int) {
+	if off == 0 {
+		node.start += n
+		return
+	}
+	pos := node.start + off
+	q.setBuf(node, slices.Delete(node.buf, pos, pos+n))
+}
+
+// compact gives back the space of deleted entries. A buffer that is mostly empty is
+// reallocated, and the entries move back to the start of the buffer once more bytes were
+// popped from the head than are left, so a node used as a queue does not keep growing.
+func (q *Quicklist) compact(node *quicklistNode) {
+	size := node.size()
+	switch {
+	case cap(node.buf) > quicklistMinShrink && cap(node.buf) > 4*size:
+		q.setBuf(node, slices.Clone(node.live()))
+		node.start = 0
+	case node.start > size:
+		n := copy(node.buf, node.live())
+		q.setBuf(node, node.buf[:n])
+		node.start = 0
+	}
+}
+
+// linkAfter inserts node after prev, at the head when prev is nil.
+func (q *Quicklist) linkAfter(prev, node *quicklistNode) {
+	node.prev = prev
+	if prev == nil {
+		node.next = q.head
+		q.head = node
+	} else {
+		node.next = prev.next
+		prev.next = node
+	}
+	if node.next != nil {
+		node.next.prev = node
+	} else {
+		q.tail = node
+	}
+	q.nodes++
+}
+
+// unlink removes node from the list and releases its buffer.
+func (q *Quicklist) unlink(node *quicklistNode) {
+	if node.prev != nil {
+		node.prev.next = node.next
+	} else {
+		q.head = node.next
+	}
+	if node.next != nil {
+		node.next.prev = node.prev
+	} else {
+		q.tail = node.prev
+	}
+	node.prev, node.next = nil, nil
+	q.setBuf(node, nil)
+	q.nodes--
+}
+
+// insertAt adds value as entry i of node, splitting the node when it is full.
+func (q *Quicklist) insertAt(node *quicklistNode, i int, value string) {
+	size := packedSize(value)
+	if !node.allowInsert(size) {
+		switch {
+		case i == 0 && (node.prev == nil || !node.prev.allowInsert(size)):
+			node = q.newNodeAfter(node.prev)
+		case i == 0:
+			node = node.prev
+			i = node.count
+		case i == node.count && (node.next == nil || !node.next.allowInsert(size)):
+			node = q.newNodeAfter(node)
+			i = 0
+		case i == node.count:
+			node = node.next
+			i = 0
+		default:
+			// move the entries after i to a new node, value goes at the end of node
+			off := node.offset(i)
+			right := q.newNodeAfter(node)
+			copy(q.openGap(right, 0, node.size()-off), node.live()[off:])
+			right.count = node.count - i
+			q.setBuf(node, node.buf[:node.start+off])
+			node.count = i
+		}
+	}
+	putEntry(q.openGap(node, node.offset(i), size), value)
+	node.count++
+	q.count++
+}
+
+func (q *Quicklist) newNodeAfter(prev *quicklistNode) *quicklistNode {
+	node := &quicklistNode{}
+	q.linkAfter(prev, node)
+	return node
+}
+
+// deleteAt removes the entry of n bytes at off of node, and the node once it is empty.
+func (q *Quicklist) deleteAt(node *quicklistNode, off, n int) {
+	q.deleteRange(node, off, n)
+	node.count--
+	q.count--
+	if node.count == 0 {
+		q.unlink(node)
+		return
+	}
+	q.compact(node)
+}
+
+// merge moves the entries of right into left, its previous node, when they fit in one
+// node. Deleting in the middle of the list then does not leave many small nodes behind.
+func (q *Quicklist) merge(left, right *quicklistNode) {
+	if left == nil || right == nil || !left.fits(right) {
+		return
+	}
+	copy(q.openGap(left, left.size(), right.size()), right.live())
+	left.count += right.count
+	q.unlink(right)
+}
+
+// PushHead adds value at the head of the list.
+func (q *Quicklist) PushHead(value string) {
+	if q.head == nil {
+		q.newNodeAfter(nil)
+	}
+	q.insertAt(q.head, 0, value)
+}
+
+// PushTail adds value at the tail of the list.
+func (q *Quicklist) PushTail(value string) {
+	if q.tail == nil {
+		q.newNodeAfter(nil)
+	}
+	q.insertAt(q.tail, q.tail.count, value)
+}
+
+// PopHead removes and returns the first element.
+func (q *Quicklist) PopHead() (string, bool) {
+	if q.head == nil {
+		return "", false
+	}
+	b, end := entryAt(q.head.live(), 0)
+	value := string(b)
+	q.deleteAt(q.head, 0, end)
+	return value, true
+}
+
+// PopTail removes and returns the last element.
+func (q *Quicklist) PopTail() (string, bool) {
+	if q.tail == nil {
+		return "", false
+	}
+	live := q.tail.live()
+	off := prevEntry(live, len(live))
+	b, _ := entryAt(live, off)
+	value := string(b)
+	q.deleteAt(q.tail, off, len(live)-off)
+	return value, true
+}
+
+func (q *Quicklist) Len() int {
+	return q.count
+}
+
+// locate returns the node holding the element at index, 0 <= index < Len(), and its
+// position in the node. The walk starts from the closest end.
+func (q *Quicklist) locate(index int) (*quicklistNode, int) {
+	if index < q.count/2 {
+		node := q.head
+		for index >= node.count {
+			index -= node.count
+			node = node.next
+		}
+		return node, index
+	}
+	index = q.count - 1 - index
+	node := q.tail
+	for index >= node.count {
+		index -= node.count
+		node = node.prev
+	}
+	return node, node.count - 1 - index
+}
+
+// normalizeIndex converts a negative index, counting from the tail, and reports
+// whether it is within the list.
+func (q *Quicklist) normalizeIndex(index int) (int, bool) {
+	if index < 0 {
+		index += q.count
+	}
+	return index, index >= 0 && index < q.count
+}
+
+// Index returns the element at index, negative indexes count from the tail.
+func (q *Quicklist) Index(index int) (string, bool) {
+	index, ok := q.normalizeIndex(index)
+	if !ok {
+		return "", false
+	}
+	node, i := q.locate(index)
+	b, _ := entryAt(node.live(), node.offset(i))
+	return string(b), true
+}
+
+// Set replaces the element at index, negative indexes count from the tail.
+func (q *Quicklist) Set(index int, value string) bool {
+	index, ok := q.normalizeIndex(index)
+	if !ok {
+		return false
+	}
+	node, i := q.locate(index)
+	off := node.offset(i)
+	_, end := entryAt(node.live(), off)
+	q.deleteRange(node, off, end-off)
+	putEntry(q.openGap(node, off, packedSize(value)), value)
+	return true
+}
+
+// Range calls fn on the elements from start to end included, 0 <= start <= end < Len(),
+// until it returns false.
+func (q *Quicklist) Range(start, end int, fn func(value string) bool) {
+	node, i := q.locate(start)
+	off := node.offset(i)
+	for n := end - start + 1; n > 0; n-- {
+		if off == node.size() {
+			node, off = node.next, 0
+		}
+		b, next := entryAt(node.live(), off)
+		if !fn(string(b)) {
+			return
+		}
+		off = next
+	}
+}
+
+// ForEach calls fn with the index of every element from the head, or from the tail when
+// reverse is set, until it returns false.
+func (q *Quicklist) ForEach(reverse bool, fn func(index int, value string) bool) {
+	if !reverse {
+		index := 0
+		for node := q.head; node != nil; node = node.next {
+			live := node.live()
+			for off := 0; off < len(live); index++ {
+				var b []byte
+				b, off = entryAt(live, off)
+				if !fn(index, string(b)) {
+					return
+				}
+			}
+		}
+		return
+	}
+	index := q.count - 1
+	for node := q.tail; node != nil; node = node.prev {
+		live := node.live()
+		for end := len(live); end > 0; index-- {
+			end = prevEntry(live, end)
+			b, _ := entryAt(live, end)
+			if !fn(index, string(b)) {
+				return
+			}
+		}
+	}
+}
+
+// Insert adds value before or after the first occurrence of pivot from the head. It
+// returns false when pivot is not in the list.
+func (q *Quicklist) Insert(pivot, value string, after bool) bool {
+	for node := q.head; node != nil; node = node.next {
+		live := node.live()
+		for i, off := 0, 0; off < len(live); i++ {
+			var b []byte
+			b, off = entryAt(live, off)
+			if string(b) != pivot {
+				continue
+			}
+			if after {
+				i++
+			}
+			q.insertAt(node, i, value)
+			return true
+		}
+	}
+	return false
+}
+
+// Remove deletes up to count occurrences of value, from the head when count is positive
+// and from the tail when it is negative, every occurrence when it is 0. It returns the
+// number of deleted elements.
+func (q *Quicklist) Remove(value string, count int) int {
+	removed := 0
+	if count >= 0 {
+		for node := q.head; node != nil; {
+			next := node.next
+			for off := 0; off < node.size() && (count == 0 || removed < count); {
+				b, end := entryAt(node.live(), off)
+				if string(b) != value {
+					off = end
+					continue
+				}
+				q.deleteAt(node, off, end-off)
+				removed++
+			}
+			if node.count > 0 {
+				// the previous node was already visited
+				q.merge(node.prev, node)
+			}
+			if count != 0 && removed == count {
+				break
+			}
+			node = next
+		}
+		return removed
+	}
+	for node := q.tail; node != nil; {
+		prev := node.prev
+		// deleting an entry leaves the offsets of the ones before it unchanged
+		for end := node.size(); end > 0 && removed < -count; {
+			off := prevEntry(node.live(), end)
+			if b, _ := entryAt(node.live(), off); string(b) == value {
+				q.deleteAt(node, off, end-off)
+				removed++
+			}
+			end = off
+		}
+		if node.count > 0 {
+			q.merge(node, node.next)
+		}
+		if removed == -count {
+			break
+		}
+		node = prev
+	}
+	return removed
+}
+
+// dropHead deletes the first n elements, whole nodes at once.
+func (q *Quicklist) dropHead(n int) {
+	for n > 0 {
+		node := q.head
+		if node.count <= n {
+			n -= node.count
+			q.count -= node.count
+			q.unlink(node)
+			continue
+		}
+		q.deleteRange(node, 0, node.offset(n))
+		node.count -= n
+		q.count -= n
+		q.compact(node)
+		return
+	}
+}
+
+// dropTail deletes the last n elements, whole nodes at once.
+func (q *Quicklist) dropTail(n int) {
+	for n > 0 {
+		node := q.tail
+		if node.count <= n {
+			n -= node.count
+			q.count -= node.count
+			q.unlink(node)
+			continue
+		}
+		q.setBuf(node, node.buf[:node.start+node.offset(node.count-n)])
+		node.count -= n
+		q.count -= n
+		q.compact(node)
+		return
+	}
+}
+
+// Trim keeps only the elements from start to end included, with
+// 0 <= start <= end < Len(), or removes everything when keep is false.
+func (q *Quicklist) Trim(start, end int, keep bool) {
+	if !keep {
+		q.dropHead(q.count)
+		return
+	}
+	q.dropTail(q.count - 1 - end)
+	q.dropHead(start)
+}
+
+// Encoding returns ObjEncodingListpack while the list fits in a single node and
+// ObjEncodingQuicklist afterwards.
+func (q *Quicklist) Encoding() uint8 {
+	if q.nodes <= 1 {
+		return ObjEncodingListpack
+	}
+	return ObjEncodingQuicklist
+}
+
+// Copy returns a list with the same elements.
+func (q *Quicklist) Copy() *Quicklist {
+	res := NewQuicklist()
+	for node := q.head; node != nil; node = node.next {
+		dup := &quicklistNode{count: node.count}
+		res.setBuf(dup, slices.Clone(node.live()))
+		res.linkAfter(res.tail, dup)
+	}
+	res.count = q.count
+	return res
+}
+
+// MemoryUsage returns the approximate bytes held by the list.
+func (q *Quicklist) MemoryUsage() int64 {
+	return 48 + int64(q.nodes)*quicklistNodeSize + q.bytes
+}
+
+// release unlinks every node, the list must not be used afterwards.
+func (q *Quicklist) release() {
+	for node := q.head; node != nil; {
+		next := node.next
+		node.prev, node.next, node.buf = nil, nil, nil
+		node = next
+	}
+	q.head, q.tail = nil, nil
+}
@@ -0,0 +1,157 @@
+package data_structure_test
+
+import (
+	"math/rand"
+	"redis-clone/internal/config"
+	"redis-clone/internal/data_structure"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func listElements(q *data_structure.Quicklist) []string {
+	res := []string{}
+	q.ForEach(false, func(_ int, value string) bool {
+		res = append(res, value)
+		return true
+	})
+	return res
+}
+
+func TestQuicklistMatchesSlice(t *testing.T) {
+	for _, fill := range []int{3, -1} {
+		old := config.ListMaxListpackSize
+		config.ListMaxListpackSize = fill
+		q := data_structure.NewQuicklist()
+		var model []string
+		r := rand.New(rand.NewSource(1))
+		for step := 0; step < 5000; step++ {
+			value := strconv.Itoa(r.Intn(20))
+			if fill < 0 {
+				// big enough that a 4 KB node only holds a few of them
+				value += string(make([]byte, r.Intn(1500)))
+			}
+			switch op := r.Intn(9); {
+			case op <= 1:
+				q.PushHead(value)
+				model = slices.Insert(model, 0, value)
+			case op <= 3:
+				q.PushTail(value)
+				model = append(model, value)
+			case op == 4:
+				v, ok := q.PopHead()
+				assert.Equal(t, len(model) > 0, ok)
+				if ok {
+					assert.Equal(t, model[0], v)
+					model = model[1:]
+				}
+			case op == 5:
+				v, ok := q.PopTail()
+				assert.Equal(t, len(model) > 0, ok)
+				if ok {
+					assert.Equal(t, model[len(model)-1], v)
+					model = model[:len(model)-1]
+				}
+			case op == 6 && len(model) > 0:
+				pivot := model[r.Intn(len(model))]
+				i := slices.Index(model, pivot)
+				assert.True(t, q.Insert(pivot, value, true))
+				model = slices.Insert(model, i+1, value)
+			case op == 7 && len(model) > 0:
+				target, count := model[r.Intn(len(model))], r.Intn(5)-2
+				want := 0
+				if count >= 0 {
+					for i := 0; i < len(model); {
+						if model[i] == target && (count == 0 || want < count) {
+							model = slices.Delete(model, i, i+1)
+							want++
+						} else {
+							i++
+						}
+					}
+				} else {
+					for i := len(model) - 1; i >= 0 && want < -count; i-- {
+						if model[i] == target {
+							model = slices.Delete(model, i, i+1)
+							want++
+						}
+					}
+				}
+				assert.Equal(t, want, q.Remove(target, count))
+			case op == 8 && len(model) > 0:
+				i := r.Intn(len(model))
+				assert.True(t, q.Set(i-len(model), value))
+				model[i] = value
+			}
+			assert.Equal(t, len(model), q.Len())
+		}
+		assert.Equal(t, model, listElements(q))
+		for i := range model {
+			v, ok := q.Index(i)
+			assert.True(t, ok)
+			assert.Equal(t, model[i], v)
+		}
+		_, ok := q.Index(len(model))
+		assert.False(t, ok)
+		assert.Equal(t, model, listElements(q.Copy()))
+		config.ListMaxListpackSize = old
+	}
+}
+
+func TestQuicklistEncoding(t *testing.T) {
+	old := config.ListMaxListpackSize
+	defer func() { config.ListMaxListpackSize = old }()
+	config.ListMaxListpackSize = 4
+	q := data_structure.NewQuicklist()
+	for i := 0; i < 4; i++ {
+		q.PushTail(strconv.Itoa(i))
+	}
+	assert.Equal(t, "listpack", data_structure.EncodingName(q.Encoding()))
+	q.PushHead("x")
+	assert.Equal(t, "quicklist", data_structure.EncodingName(q.Encoding()))
+
+	q.Trim(1, 2, true)
+	assert.Equal(t, []string{"0", "1"}, listElements(q))
+	q.Trim(0, 0, false)
+	assert.Equal(t, 0, q.Len())
+	_, ok := q.PopHead()
+	assert.False(t, ok)
+}
+
+func TestQuicklistMemory(t *testing.T) {
+	old := config.ListMaxListpackSize
+	defer func() { config.ListMaxListpackSize = old }()
+	config.ListMaxListpackSize = -2
+
+	// small elements are packed with a few bytes of header each
+	q := data_structure.NewQuicklist()
+	for i := 0; i < 100000; i++ {
+		q.PushTail(strconv.Itoa(i % 1000))
+	}
+	assert.Less(t, q.MemoryUsage(), int64(100000*8))
+	assert.Equal(t, "quicklist", data_structure.EncodingName(q.Encoding()))
+
+	// a queue fed at the tail and drained at the head does not keep what it popped
+	q = data_structure.NewQuicklist()
+	var peak int64
+	for i := 0; i < 100000; i++ {
+		q.PushTail("job:" + strconv.Itoa(i))
+		if i >= 10 {
+			v, _ := q.PopHead()
+			assert.Equal(t, "job:"+strconv.Itoa(i-10), v)
+		}
+		peak = max(peak, q.MemoryUsage())
+	}
+	assert.Equal(t, 10, q.Len())
+	assert.Less(t, peak, int64(1024))
+
+	// and the same the other way around
+	for i := 0; i < 100000; i++ {
+		q.PushHead("job")
+		q.PopTail()
+	}
+	assert.Equal(t, 10, q.Len())
+	assert.Less(t, q.MemoryUsage(), int64(1024))
+}
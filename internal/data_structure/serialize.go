@@ -0,0 +1,222 @@
+package data_structure
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"redis-clone/internal/constant"
+)
+
+// Value types of the serialization format. A value is its type byte followed by its
+// body, lengths and counts are unsigned varints and floats are 8 bytes little endian.
+const (
+	serialTypeString uint8 = iota
+	serialTypeSet
+	serialTypeZSet
+	serialTypeCMS
+	serialTypeBloom
+	serialTypeList
+)
+
+// ErrBadDataFormat is returned when serialized data cannot be decoded.
+var ErrBadDataFormat = errors.New("ERR Bad data format")
+
+// SerializeValue appends the serialization of a keyspace value to buf and returns the
+// extended buffer. It only depends on the value, the key and its TTL are stored by the
+// caller.
+func SerializeValue(buf []byte, value interface{}) []byte {
+	switch v := value.(type) {
+	case *SimpleSet:
+		buf = append(buf, serialTypeSet)
+		// members are stored as strings, loading them picks the encoding again
+		members := v.Members()
+		buf = binary.AppendUvarint(buf, uint64(len(members)))
+		for _, member := range members {
+			buf = appendString(buf, member)
+		}
+	case *SortedSet:
+		buf = append(buf, serialTypeZSet)
+		items := v.Items()
+		buf = binary.AppendUvarint(buf, uint64(len(items)))
+		for _, item := range items {
+			buf = appendString(buf, item.Member)
+			buf = appendFloat(buf, item.Score)
+		}
+	case *CMS:
+		buf = append(buf, serialTypeCMS)
+		buf = binary.AppendUvarint(buf, uint64(v.width))
+		buf = binary.AppendUvarint(buf, uint64(v.depth))
+		for _, row := range v.counter {
+			for _, count := range row {
+				buf = binary.AppendUvarint(buf, uint64(count))
+			}
+		}
+	case *Bloom:
+		buf = append(buf, serialTypeBloom)
+		buf = binary.AppendUvarint(buf, v.Entries)
+		buf = appendFloat(buf, v.Error)
+		buf = binary.AppendUvarint(buf, uint64(v.Hashes))
+		buf = appendString(buf, string(v.bf))
+	case *Quicklist:
+		buf = append(buf, serialTypeList)
+		buf = binary.AppendUvarint(buf, uint64(v.Len()))
+		v.ForEach(false, func(_ int, value string) bool {
+			buf = appendString(buf, value)
+			return true
+		})
+	default:
+		buf = append(buf, serialTypeString)
+		buf = appendString(buf, StringValue(v))
+	}
+	return buf
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendFloat(buf []byte, f float64) []byte {
+	return binary.LittleEndian.AppendUint64(buf, math.Float64bits(f))
+}
+
+// DeserializeValue decodes a value written by SerializeValue at the start of data. It
+// returns the value, stored under key when its type needs it, and the bytes after it.
+func DeserializeValue(key string, data []byte) (interface{}, []byte, error) {
+	r := &serialReader{data: data}
+	value := r.value(key)
+	if r.err != nil {
+		return nil, nil, r.err
+	}
+	return value, r.data, nil
+}
+
+// serialReader decodes serialized data, after the first error every read returns a zero
+// value and err keeps that error.
+type serialReader struct {
+	data []byte
+	err  error
+}
+
+func (r *serialReader) fail() {
+	r.err = ErrBadDataFormat
+	r.data = nil
+}
+
+func (r *serialReader) byte() uint8 {
+	if len(r.data) < 1 {
+		r.fail()
+		return 0
+	}
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b
+}
+
+func (r *serialReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+// count reads a number of elements, every element takes at least one byte so a count
+// larger than the remaining data is corrupt.
+func (r *serialReader) count() int {
+	n := r.uvarint()
+	if n > uint64(len(r.data)) {
+		r.fail()
+		return 0
+	}
+	return int(n)
+}
+
+func (r *serialReader) string() string {
+	n := r.count()
+	s := string(r.data[:n])
+	r.data = r.data[n:]
+	return s
+}
+
+func (r *serialReader) float() float64 {
+	if len(r.data) < 8 {
+		r.fail()
+		return 0
+	}
+	f := math.Float64frombits(binary.LittleEndian.Uint64(r.data))
+	r.data = r.data[8:]
+	return f
+}
+
+func (r *serialReader) value(key string) interface{} {
+	switch r.byte() {
+	case serialTypeString:
+		return NewString(r.string())
+	case serialTypeSet:
+		set := NewSimpleSet(key)
+		n := r.count()
+		for i := 0; i < n && r.err == nil; i++ {
+			set.Add(r.string())
+		}
+		return set
+	case serialTypeZSet:
+		zset := NewSortedSet(constant.DefaultBPlusTreeDegree)
+		n := r.count()
+		for i := 0; i < n && r.err == nil; i++ {
+			member := r.string()
+			score := r.float()
+			if math.IsNaN(score) {
+				r.fail()
+			}
+			zset.Add(score, member)
+		}
+		return zset
+	case serialTypeCMS:
+		width, depth := r.uvarint(), r.uvarint()
+		if width == 0 || depth == 0 || width > math.MaxUint32 || depth > math.MaxUint32 ||
+			width*depth > uint64(len(r.data)) {
+			r.fail()
+			return nil
+		}
+		cms := CreateCMS(uint32(width), uint32(depth))
+		for _, row := range cms.counter {
+			for j := range row {
+				count := r.uvarint()
+				if count > math.MaxUint32 {
+					r.fail()
+				}
+				row[j] = uint32(count)
+			}
+		}
+		return cms
+	case serialTypeBloom:
+		entries := r.uvarint()
+		errRate := r.float()
+		hashes := r.uvarint()
+		bf := r.string()
+		if r.err != nil || entries == 0 || !(errRate > 0 && errRate < 1) ||
+			float64(entries)*calcBpe(errRate) > float64(len(bf))*8 {
+			r.fail()
+			return nil
+		}
+		bloom := CreateBloomFilter(entries, errRate)
+		if len(bloom.bf) != len(bf) || hashes != uint64(bloom.Hashes) {
+			r.fail()
+			return nil
+		}
+		copy(bloom.bf, bf)
+		return bloom
+	case serialTypeList:
+		list := NewQuicklist()
+		n := r.count()
+		for i := 0; i < n && r.err == nil; i++ {
+			list.PushTail(r.string())
+		}
+		return list
+	}
+	r.fail()
+	return nil
+}
@@ -0,0 +1,64 @@
+package data_structure_test
+
+import (
+	"fmt"
+	"redis-clone/internal/data_structure"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSerializeValue(t *testing.T) {
+	intset := data_structure.NewSimpleSet("s")
+	intset.Add("3", "1", "2")
+	set := data_structure.NewSimpleSet("s")
+	set.Add("a", "b", "c")
+	zset := data_structure.NewSortedSet(4)
+	for i := 0; i < 200; i++ {
+		zset.Add(float64(i%7), fmt.Sprintf("m%d", i))
+	}
+	cms := data_structure.CreateCMS(100, 4)
+	cms.IncrBy("hello", 7)
+	bloom := data_structure.CreateBloomFilter(1000, 0.01)
+	bloom.Add("hello")
+	list := data_structure.NewQuicklist()
+	for i := 0; i < 300; i++ {
+		list.PushTail(fmt.Sprintf("e%d", i))
+	}
+
+	for _, value := range []interface{}{"", "hello", intset, set, zset, cms, bloom, list} {
+		buf := data_structure.SerializeValue([]byte("prefix"), value)
+		got, rest, err := data_structure.DeserializeValue("s", append(buf[len("prefix"):], "next"...))
+		assert.NoError(t, err)
+		assert.Equal(t, "next", string(rest))
+		assertSameValue(t, value, got)
+
+		// every truncation is detected
+		for i := len("prefix"); i < len(buf); i++ {
+			_, _, err := data_structure.DeserializeValue("s", buf[len("prefix"):i])
+			assert.ErrorIs(t, err, data_structure.ErrBadDataFormat)
+		}
+	}
+	_, _, err := data_structure.DeserializeValue("s", []byte{255})
+	assert.ErrorIs(t, err, data_structure.ErrBadDataFormat)
+}
+
+// assertSameValue compares values through their API, hash tables are seeded randomly.
+func assertSameValue(t *testing.T, want, got interface{}) {
+	switch w := want.(type) {
+	case *data_structure.SimpleSet:
+		g := got.(*data_structure.SimpleSet)
+		assert.ElementsMatch(t, w.Members(), g.Members())
+		assert.Equal(t, w.Encoding(), g.Encoding())
+	case *data_structure.SortedSet:
+		g := got.(*data_structure.SortedSet)
+		assert.ElementsMatch(t, w.Items(), g.Items())
+		assert.Equal(t, w.Encoding(), g.Encoding())
+	case *data_structure.Quicklist:
+		g := got.(*data_structure.Quicklist)
+		assert.Equal(t, listElements(w), listElements(g))
+		assert.Equal(t, w.Encoding(), g.Encoding())
+	default:
+		assert.Equal(t, want, got)
+	}
+}
@@ -1,22 +1,66 @@
 package data_structure
 
+import (
+	"redis-clone/internal/config"
+	"slices"
+	"strconv"
+)
+
+// SimpleSet starts as an intset, a sorted slice of integers, and converts to a hash
+// table once a member is not an integer or it holds more than config.SetMaxIntsetEntries.
 type SimpleSet struct {
-	key  string
-	dict map[string]struct{}
+	key string
+	// intset holds the members while dict is nil
+	intset []int64
+	dict   *Hashtable[struct{}]
+	// bytes is the size of the members and their entries
+	bytes int64
 }
 
 func NewSimpleSet(key string) *SimpleSet {
 	return &SimpleSet{
-		key:  key,
-		dict: make(map[string]struct{}),
+		key: key,
+	}
+}
+
+// isIntset reports whether the set still uses the intset encoding.
+func (s *SimpleSet) isIntset() bool {
+	return s.dict == nil
+}
+
+// convertToHashtable moves the members of the intset to a hash table.
+func (s *SimpleSet) convertToHashtable() {
+	s.dict = NewHashtable[struct{}]()
+	s.bytes = 0
+	for _, v := range s.intset {
+		member := strconv.FormatInt(v, 10)
+		s.dict.Set(member, struct{}{})
+		s.bytes += hashtableEntrySize + int64(len(member))
 	}
+	s.intset = nil
 }
 
 func (s *SimpleSet) Add(members ...string) int {
 	added := 0
 	for _, member := range members {
-		if _, exist := s.dict[member]; !exist {
-			s.dict[member] = struct{}{}
+		if s.isIntset() {
+			v, isInt := parseCanonicalInt(member)
+			if isInt {
+				i, found := slices.BinarySearch(s.intset, v)
+				if found {
+					continue
+				}
+				if len(s.intset) < config.SetMaxIntsetEntries {
+					s.intset = slices.Insert(s.intset, i, v)
+					s.bytes += 8
+					added++
+					continue
+				}
+			}
+			s.convertToHashtable()
+		}
+		if s.dict.Set(member, struct{}{}) {
+			s.bytes += hashtableEntrySize + int64(len(member))
 			added++
 		}
 	}
@@ -26,8 +70,20 @@ func (s *SimpleSet) Add(members ...string) int {
 func (s *SimpleSet) Rem(members ...string) int {
 	removed := 0
 	for _, member := range members {
-		if _, exist := s.dict[member]; exist {
-			delete(s.dict, member)
+		if s.isIntset() {
+			v, isInt := parseCanonicalInt(member)
+			if !isInt {
+				continue
+			}
+			if i, found := slices.BinarySearch(s.intset, v); found {
+				s.intset = slices.Delete(s.intset, i, i+1)
+				s.bytes -= 8
+				removed++
+			}
+			continue
+		}
+		if s.dict.Delete(member) {
+			s.bytes -= hashtableEntrySize + int64(len(member))
 			removed++
 		}
 	}
@@ -35,7 +91,17 @@ func (s *SimpleSet) Rem(members ...string) int {
 }
 
 func (s *SimpleSet) IsMember(member string) int {
-	_, exist := s.dict[member]
+	if s.isIntset() {
+		v, isInt := parseCanonicalInt(member)
+		if !isInt {
+			return 0
+		}
+		if _, found := slices.BinarySearch(s.intset, v); found {
+			return 1
+		}
+		return 0
+	}
+	_, exist := s.dict.Get(member)
 	if exist {
 		return 1
 	}
@@ -43,9 +109,70 @@ func (s *SimpleSet) IsMember(member string) int {
 }
 
 func (s *SimpleSet) Members() []string {
-	member := make([]string, 0, len(s.dict))
-	for k, _ := range s.dict {
-		member = append(member, k)
+	member := make([]string, 0, s.Len())
+	if s.isIntset() {
+		for _, v := range s.intset {
+			member = append(member, strconv.FormatInt(v, 10))
+		}
+		return member
 	}
+	s.dict.ForEach(func(k string, _ struct{}) bool {
+		member = append(member, k)
+		return true
+	})
 	return member
 }
+
+func (s *SimpleSet) Len() int {
+	if s.isIntset() {
+		return len(s.intset)
+	}
+	return s.dict.Len()
+}
+
+// Encoding returns ObjEncodingIntset or ObjEncodingHashtable.
+func (s *SimpleSet) Encoding() uint8 {
+	if s.isIntset() {
+		return ObjEncodingIntset
+	}
+	return ObjEncodingHashtable
+}
+
+// Copy returns a set with the same members stored under key.
+func (s *SimpleSet) Copy(key string) *SimpleSet {
+	res := NewSimpleSet(key)
+	if s.isIntset() {
+		res.intset = slices.Clone(s.intset)
+	} else {
+		res.dict = NewHashtable[struct{}]()
+		s.dict.ForEach(func(member string, _ struct{}) bool {
+			res.dict.Set(member, struct{}{})
+			return true
+		})
+	}
+	res.bytes = s.bytes
+	return res
+}
+
+// MemoryUsage returns the approximate bytes held by the set.
+func (s *SimpleSet) MemoryUsage() int64 {
+	size := stringHeaderSize + int64(len(s.key)) + s.bytes
+	if s.isIntset() {
+		return size + sliceHeaderSize
+	}
+	return size + s.dict.MemoryOverhead()
+}
+
+// Scan visits the members of one bucket, see Hashtable.Scan. Like in Redis an intset
+// is small enough to be returned in one call.
+func (s *SimpleSet) Scan(cursor uint64, fn func(member string)) uint64 {
+	if s.isIntset() {
+		for _, v := range s.intset {
+			fn(strconv.FormatInt(v, 10))
+		}
+		return 0
+	}
+	return s.dict.Scan(cursor, func(member string, _ struct{}) {
+		fn(member)
+	})
+}
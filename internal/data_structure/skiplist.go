@@ -243,3 +243,12 @@ func (sl *Skiplist) Delete(score float64, ele string) int {
 	}
 	return 0
 }
+
+// First returns the score and element of the lowest ranked node.
+func (sl *Skiplist) First() (float64, string, bool) {
+	x := sl.head.levels[0].forward
+	if x == nil {
+		return 0, "", false
+	}
+	return x.score, x.ele, true
+}
@@ -1,25 +1,168 @@
 package data_structure
 
+import (
+	"redis-clone/internal/config"
+	"slices"
+)
+
+// SortedSet starts as a listpack, a slice of items sorted by score then member, and
+// converts to a B+ tree with a member to score dict once it holds more than
+// config.ZsetMaxListpackEntries members.
 type SortedSet struct {
+	// listpack holds the members while Tree is nil
+	listpack     []Item
 	Tree         *BPlusTree
-	MemberScores map[string]float64
+	MemberScores *Hashtable[float64]
+	degree       int
+	// bytes is the size of the members in the tree and in MemberScores
+	bytes int64
 }
 
 func NewSortedSet(degree int) *SortedSet {
 	return &SortedSet{
-		Tree:         NewBPlusTree(degree),
-		MemberScores: make(map[string]float64),
+		degree: degree,
+	}
+}
+
+// isListpack reports whether the sorted set still uses the listpack encoding.
+func (ss *SortedSet) isListpack() bool {
+	return ss.Tree == nil
+}
+
+// listpackIndex returns the position of member in the listpack, or -1.
+func (ss *SortedSet) listpackIndex(member string) int {
+	for i := range ss.listpack {
+		if ss.listpack[i].Member == member {
+			return i
+		}
+	}
+	return -1
+}
+
+// listpackInsert inserts an item at its sorted position.
+func (ss *SortedSet) listpackInsert(score float64, member string) {
+	item := Item{Score: score, Member: member}
+	i, _ := slices.BinarySearchFunc(ss.listpack, &item, func(e Item, target *Item) int {
+		return e.CompareTo(target)
+	})
+	ss.listpack = slices.Insert(ss.listpack, i, item)
+}
+
+// convertToBPlusTree moves the members of the listpack to a B+ tree.
+func (ss *SortedSet) convertToBPlusTree() {
+	ss.Tree = NewBPlusTree(ss.degree)
+	ss.MemberScores = NewHashtable[float64]()
+	ss.bytes = 0
+	for _, item := range ss.listpack {
+		ss.Tree.Add(item.Score, item.Member)
+		ss.MemberScores.Set(item.Member, item.Score)
+		ss.bytes += zsetMemberSize + int64(len(item.Member))
 	}
+	ss.listpack = nil
 }
 
 func (ss *SortedSet) Add(score float64, member string) int {
-	return ss.Tree.Add(score, member)
+	if len(member) == 0 {
+		return 0
+	}
+	if ss.isListpack() {
+		i := ss.listpackIndex(member)
+		if i >= 0 {
+			// the score changed, move the member to its new position
+			ss.listpack = slices.Delete(ss.listpack, i, i+1)
+			ss.listpackInsert(score, member)
+			return 1
+		}
+		if len(ss.listpack) < config.ZsetMaxListpackEntries {
+			ss.listpackInsert(score, member)
+			ss.bytes += stringHeaderSize + 8 + int64(len(member))
+			return 1
+		}
+		ss.convertToBPlusTree()
+	}
+	ret := ss.Tree.Add(score, member)
+	if ret == 1 && ss.MemberScores.Set(member, score) {
+		ss.bytes += zsetMemberSize + int64(len(member))
+	}
+	return ret
 }
 
 func (ss *SortedSet) GetScore(member string) (float64, bool) {
-	return ss.Tree.Score(member)
+	if ss.isListpack() {
+		if i := ss.listpackIndex(member); i >= 0 {
+			return ss.listpack[i].Score, true
+		}
+		return 0, false
+	}
+	return ss.MemberScores.Get(member)
+}
+
+func (ss *SortedSet) Len() int {
+	if ss.isListpack() {
+		return len(ss.listpack)
+	}
+	return ss.MemberScores.Len()
 }
 
 func (ss *SortedSet) GetRank(member string) int {
+	if ss.isListpack() {
+		return ss.listpackIndex(member)
+	}
 	return ss.Tree.GetRank(member)
 }
+
+// Encoding returns ObjEncodingListpack or ObjEncodingBPlusTree.
+func (ss *SortedSet) Encoding() uint8 {
+	if ss.isListpack() {
+		return ObjEncodingListpack
+	}
+	return ObjEncodingBPlusTree
+}
+
+// Copy returns a sorted set with the same members and scores.
+func (ss *SortedSet) Copy() *SortedSet {
+	res := NewSortedSet(ss.degree)
+	if ss.isListpack() {
+		res.listpack = slices.Clone(ss.listpack)
+		res.bytes = ss.bytes
+		return res
+	}
+	for _, item := range ss.Tree.Items() {
+		res.Add(item.Score, item.Member)
+	}
+	return res
+}
+
+// MemoryUsage returns the approximate bytes held by the sorted set.
+func (ss *SortedSet) MemoryUsage() int64 {
+	if ss.isListpack() {
+		return sliceHeaderSize + ss.bytes
+	}
+	// tree nodes hold up to Degree-1 items, count them half full
+	nodes := int64(ss.Len()/max(ss.Tree.Degree/2, 1) + 1)
+	return nodes*(2*sliceHeaderSize+4*pointerSize) + ss.MemberScores.MemoryOverhead() + ss.bytes
+}
+
+// Scan visits the members of one bucket of the member to score dict, see Hashtable.Scan.
+// Like in Redis a listpack is small enough to be returned in one call.
+func (ss *SortedSet) Scan(cursor uint64, fn func(member string, score float64)) uint64 {
+	if ss.isListpack() {
+		for _, item := range ss.listpack {
+			fn(item.Member, item.Score)
+		}
+		return 0
+	}
+	return ss.MemberScores.Scan(cursor, fn)
+}
+
+// Items returns the members ordered by score then member.
+func (ss *SortedSet) Items() []Item {
+	if ss.isListpack() {
+		return slices.Clone(ss.listpack)
+	}
+	items := make([]Item, 0, ss.Len())
+	for _, item := range ss.Tree.Items() {
+		items = append(items, *item)
+	}
+	return items
+}
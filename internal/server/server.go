@@ -1,6 +1,7 @@
 package server
 
 import (
+	"errors"
 	"io"
 	"log"
 	"net"
@@ -9,6 +10,7 @@ import (
 	"redis-clone/internal/constant"
 	"redis-clone/internal/core"
 	"redis-clone/internal/core/io_multiplexing"
+	"redis-clone/internal/data_structure"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -17,16 +19,75 @@ import (
 
 var serverStatus int32 = constant.ServerStatusIdle
 
-func readCommand(fd int) (*core.Command, error) {
-	var buf = make([]byte, 512)
-	n, err := syscall.Read(fd, buf)
+// clients maps each connection fd to its per-connection state
+var clients = make(map[int]*core.Client)
+
+var readBuf = make([]byte, config.IOBufLen)
+
+var errQueryBufferLimit = errors.New("client query buffer limit reached")
+
+// readCommands appends whatever is available on the socket to the client's query
+// buffer and returns every complete command in it. A partial frame stays in the
+// buffer until the next read event completes it.
+func readCommands(c *core.Client) ([]*core.Command, error) {
+	n, err := syscall.Read(c.Fd, readBuf)
 	if err != nil {
 		return nil, err
 	}
 	if n == 0 {
 		return nil, io.EOF
 	}
-	return core.ParseCmd(buf[:n])
+	c.QueryBuf = append(c.QueryBuf, readBuf[:n]...)
+	cmds, consumed, err := core.ParseCmds(c.QueryBuf)
+	if err != nil {
+		c.QueryBuf = c.QueryBuf[:0]
+		return cmds, err
+	}
+	if len(c.QueryBuf)-consumed > config.ClientQueryBufferLimit {
+		return cmds, errQueryBufferLimit
+	}
+	// shift the leftover partial frame to the front so the buffer does not keep growing
+	c.QueryBuf = c.QueryBuf[:copy(c.QueryBuf, c.QueryBuf[consumed:])]
+	return cmds, nil
+}
+
+func closeClient(ioMultiplexer io_multiplexing.IOMultiplexer, c *core.Client) {
+	delete(clients, c.Fd)
+	_ = ioMultiplexer.Remove(c.Fd)
+	_ = syscall.Close(c.Fd)
+}
+
+// writeReplies flushes the client's pending output. While the kernel buffer is full the
+// fd is watched for writability only, so a slow reader stops sending us new commands
+// until it has drained its replies.
+func writeReplies(ioMultiplexer io_multiplexing.IOMultiplexer, c *core.Client) {
+	if err := c.Flush(); err != nil {
+		log.Println("err write:", err)
+		closeClient(ioMultiplexer, c)
+		return
+	}
+	if c.CloseAsap {
+		log.Println("closing client that reached its output buffer limit")
+		closeClient(ioMultiplexer, c)
+		return
+	}
+	if c.CloseAfterReply && !c.HasPendingReplies() {
+		closeClient(ioMultiplexer, c)
+		return
+	}
+	if c.HasPendingReplies() == c.WaitingWritable {
+		return
+	}
+	var op io_multiplexing.Operation = io_multiplexing.OpRead
+	if c.HasPendingReplies() {
+		op = io_multiplexing.OpWrite
+	}
+	if err := ioMultiplexer.Modify(io_multiplexing.Event{Fd: c.Fd, Op: op}); err != nil {
+		log.Println("err modify:", err)
+		closeClient(ioMultiplexer, c)
+		return
+	}
+	c.WaitingWritable = op == io_multiplexing.OpWrite
 }
 
 func WaitForSignal(wg *sync.WaitGroup, signals chan os.Signal) {
@@ -43,6 +104,9 @@ func WaitForSignal(wg *sync.WaitGroup, signals chan os.Signal) {
 func RunIoMultiplexingServer(wg *sync.WaitGroup) {
 	defer wg.Done()
 	log.Println("starting an I/O Multiplexing TCP server on", config.Port)
+	if !data_structure.ValidEvictionPolicy(config.EvictionPolicy) {
+		log.Fatalf("unknown eviction policy %q", config.EvictionPolicy)
+	}
 	listener, err := net.Listen(config.Protocol, config.Port)
 	if err != nil {
 		log.Fatal(err)
@@ -90,9 +154,9 @@ func RunIoMultiplexingServer(wg *sync.WaitGroup) {
 			atomic.SwapInt32(&serverStatus, constant.ServerStatusIdle)
 			lastActiveExpireExecTime = time.Now()
 		}
-		// wait for file descriptors in the monitoring list to be ready for I/O
-		// it is a blocking call.
-		events, err = ioMultiplexer.Wait()
+		// wait for file descriptors in the monitoring list to be ready for I/O,
+		// or until the next expire cycle is due
+		events, err = ioMultiplexer.Wait(core.ActiveExpireTimeout(lastActiveExpireExecTime))
 		if err != nil {
 			continue
 		}
@@ -113,6 +177,11 @@ func RunIoMultiplexingServer(wg *sync.WaitGroup) {
 						return
 					}
 				}
+				if err = syscall.SetNonblock(connFd, true); err != nil {
+					log.Println("err", err)
+					_ = syscall.Close(connFd)
+					continue
+				}
 				log.Printf("set up a new connection")
 				// ask epoll to monitor this connection
 				if err = ioMultiplexer.Monitor(io_multiplexing.Event{
@@ -121,21 +190,46 @@ func RunIoMultiplexingServer(wg *sync.WaitGroup) {
 				}); err != nil {
 					log.Fatal(err)
 				}
+				clients[connFd] = core.NewClient(connFd)
 			} else {
-				cmd, err := readCommand(events[i].Fd)
-				// log.Println("command: ", cmd)
+				c, ok := clients[events[i].Fd]
+				if !ok {
+					continue
+				}
+				if events[i].Op == io_multiplexing.OpWrite {
+					writeReplies(ioMultiplexer, c)
+					continue
+				}
+				if c.CloseAfterReply {
+					continue
+				}
+				cmds, err := readCommands(c)
+				// log.Println("command: ", cmds)
 				if err != nil {
 					if err == io.EOF || err == syscall.ECONNRESET {
 						log.Println("client disconnected")
-						_ = syscall.Close(events[i].Fd)
+						closeClient(ioMultiplexer, c)
 						continue
 					}
-					log.Println("read error:", err)
-					continue
+					if err == errQueryBufferLimit {
+						log.Println("closing client that reached its query buffer limit")
+						closeClient(ioMultiplexer, c)
+						continue
+					}
+					if err != syscall.EAGAIN {
+						log.Println("read error:", err)
+					}
+				}
+				for _, cmd := range cmds {
+					core.ExecuteAndResponse(cmd, c)
 				}
-				if err = core.ExecuteAndResponse(cmd, events[i].Fd); err != nil {
-					log.Println("err write:", err)
+				var protoErr *core.ProtocolError
+				if errors.As(err, &protoErr) {
+					// the rest of the stream can't be parsed, answer and hang up like Redis does
+					c.Write(core.Encode(protoErr, false))
+					c.CloseAfterReply = true
 				}
+				writeReplies(ioMultiplexer, c)
 			}
 		}
 		atomic.SwapInt32(&serverStatus, constant.ServerStatusIdle)